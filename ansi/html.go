@@ -0,0 +1,170 @@
+// Package ansi converts text with ANSI color escapes, like the ASCII art
+// of album covers, to HTML.
+package ansi
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// style is the state set by SGR escapes. Colors are CSS values, empty
+// for the default.
+type style struct {
+	fg, bg string
+	bold   bool
+}
+
+func (s style) css() string {
+	var parts []string
+	if s.fg != "" {
+		parts = append(parts, "color:"+s.fg)
+	}
+	if s.bg != "" {
+		parts = append(parts, "background-color:"+s.bg)
+	}
+	if s.bold {
+		parts = append(parts, "font-weight:bold")
+	}
+	return strings.Join(parts, ";")
+}
+
+// ToHTML converts s to HTML. Text is escaped and colored runs are wrapped
+// in spans with inline styles. Escapes other than SGR are dropped.
+func ToHTML(s string) string {
+	var b strings.Builder
+	var cur, open style
+	spanOpen := false
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\x1b')
+		if i != 0 {
+			text := s
+			if i > 0 {
+				text = s[:i]
+			}
+			if cur != open {
+				if spanOpen {
+					b.WriteString("</span>")
+					spanOpen = false
+				}
+				if css := cur.css(); css != "" {
+					fmt.Fprintf(&b, `<span style="%s">`, css)
+					spanOpen = true
+				}
+				open = cur
+			}
+			b.WriteString(html.EscapeString(text))
+			if i < 0 {
+				break
+			}
+			s = s[i:]
+			continue
+		}
+
+		// s starts with ESC, only CSI sequences are understood
+		if len(s) < 2 || s[1] != '[' {
+			s = s[1:]
+			continue
+		}
+		end := strings.IndexFunc(s[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+		if end < 0 {
+			break
+		}
+		params, final := s[2:2+end], s[2+end]
+		s = s[3+end:]
+		if final == 'm' {
+			cur = cur.apply(params)
+		}
+	}
+	if spanOpen {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// apply applies the parameters of an SGR escape, e.g. "38;2;255;0;0"
+func (s style) apply(params string) style {
+	var ps []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(p) // empty means 0
+		ps = append(ps, n)
+	}
+	for i := 0; i < len(ps); i++ {
+		switch p := ps[i]; {
+		case p == 0:
+			s = style{}
+		case p == 1:
+			s.bold = true
+		case p == 22:
+			s.bold = false
+		case p >= 30 && p <= 37:
+			s.fg = palette[p-30]
+		case p >= 90 && p <= 97:
+			s.fg = palette[p-90+8]
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = palette[p-40]
+		case p >= 100 && p <= 107:
+			s.bg = palette[p-100+8]
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			c, n := extendedColor(ps[i+1:])
+			i += n
+			if p == 38 {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+		}
+	}
+	return s
+}
+
+// extendedColor parses the arguments of 38 and 48, "5;n" or "2;r;g;b".
+// It returns the color and the number of parameters used.
+func extendedColor(ps []int) (string, int) {
+	switch {
+	case len(ps) >= 2 && ps[0] == 5:
+		return color256(ps[1]), 2
+	case len(ps) >= 4 && ps[0] == 2:
+		return rgb(ps[1], ps[2], ps[3]), 4
+	}
+	return "", len(ps)
+}
+
+// palette are the 16 basic colors, as xterm shows them
+var palette = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+func color256(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return palette[n]
+	case n < 232:
+		// 6x6x6 cube
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return rgb(level(n/36), level(n/6%6), level(n%6))
+	default:
+		v := 8 + (n-232)*10
+		return rgb(v, v, v)
+	}
+}
+
+func rgb(r, g, b int) string {
+	clamp := func(v int) int { return min(max(v, 0), 255) }
+	return fmt.Sprintf("#%02x%02x%02x", clamp(r), clamp(g), clamp(b))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// artHosts are the Spotify CDNs covers are fetched from. The page tells
+// spoli which cover to show, so any other URL is refused.
+var artHosts = []string{
+	"i.scdn.co",
+	"mosaic.scdn.co",
+	"image-cdn-ak.spotifycdn.com",
+	"image-cdn-fa.spotifycdn.com",
+}
+
+const (
+	maxArtSize   = 2 << 20
+	artTimeout   = 10 * time.Second
+	artCacheName = "art"
+)
+
+var artClient = &http.Client{
+	Timeout: artTimeout,
+	// a redirect could lead off the CDN
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// fetchArt downloads the cover at rawURL into the cache dir, unless it
+// is there already, and returns its path.
+func fetchArt(ctx context.Context, rawURL, cacheDir string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || !slices.Contains(artHosts, u.Hostname()) {
+		return "", fmt.Errorf("error fetching cover: %q is not a Spotify image", rawURL)
+	}
+
+	sum := sha256.Sum256([]byte(u.String()))
+	path := filepath.Join(cacheDir, artCacheName, hex.EncodeToString(sum[:16]))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, artTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error fetching cover: %s", err)
+	}
+	resp, err := artClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching cover: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching cover: %s", resp.Status)
+	}
+	if resp.ContentLength > maxArtSize {
+		return "", fmt.Errorf("error fetching cover: %d bytes is too large", resp.ContentLength)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("error caching cover: %s", err)
+	}
+	// write to a temporary file so a failed download doesn't stay cached
+	f, err := os.CreateTemp(filepath.Dir(path), "fetch-*")
+	if err != nil {
+		return "", fmt.Errorf("error caching cover: %s", err)
+	}
+	defer os.Remove(f.Name())
+	n, err := io.Copy(f, io.LimitReader(resp.Body, maxArtSize+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching cover: %s", err)
+	}
+	if n > maxArtSize {
+		return "", fmt.Errorf("error fetching cover: larger than %d bytes", maxArtSize)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", fmt.Errorf("error caching cover: %s", err)
+	}
+	return path, nil
+}
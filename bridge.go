@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/moritz-tiesler/spoli/ansi"
+	"github.com/moritz-tiesler/spoli/device"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/net/websocket"
+)
+
+var logWebPlayer = logging.For("web-player")
+
+// bridge is the Web Playback SDK player in the browser, a playback
+// device connected over a WebSocket. The page publishes the SDK's events
+// and the broker drives the player with the same events the TUI sends.
+type bridge struct {
+	host device.Host
+
+	mu   sync.Mutex
+	ws   *websocket.Conn // the connected page, a new one replaces it
+	conn *device.Conn
+	art  string
+
+	// covers are downloaded here
+	cacheDir string
+}
+
+func newBridge(host device.Host, cacheDir string) *bridge {
+	return &bridge{host: host, cacheDir: cacheDir}
+}
+
+// handler accepts pages from allowed origins with the session secret
+func (br *bridge) handler(s *session) http.Handler {
+	return websocket.Server{
+		Handshake: func(cfg *websocket.Config, r *http.Request) error {
+			o, err := websocket.Origin(cfg, r)
+			if err != nil || o == nil || !s.allowed(o.Scheme+"://"+o.Host, r) {
+				return fmt.Errorf("origin %q not allowed", r.Header.Get("Origin"))
+			}
+			if !s.valid(r) {
+				return fmt.Errorf("missing or invalid session")
+			}
+			cfg.Origin = o
+			return nil
+		},
+		Handler: br.serve,
+	}
+}
+
+func (br *bridge) serve(ws *websocket.Conn) {
+	conn := device.NewConn(br.host, func(m device.Message) error {
+		return websocket.JSON.Send(ws, m)
+	})
+	br.mu.Lock()
+	if br.ws != nil {
+		br.ws.Close()
+	}
+	br.ws, br.conn, br.art = ws, conn, ""
+	br.mu.Unlock()
+
+	defer func() {
+		br.mu.Lock()
+		if br.ws == ws {
+			br.ws, br.conn = nil, nil
+		}
+		br.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		var m device.Message
+		if err := websocket.JSON.Receive(ws, &m); err != nil {
+			logWebPlayer.Info("web player disconnected", "err", err)
+			if conn.ID() != "" {
+				br.host.Publish(event.New(event.SDK_NOT_READY, nil))
+			}
+			return
+		}
+		if err := conn.Receive(m); err != nil {
+			logWebPlayer.Warn("invalid message from web player", "err", err)
+		}
+		if st := conn.State(); m.Event == event.SDK_STATE.String() && st != nil {
+			br.showArt(ws, st.Image)
+		}
+	}
+}
+
+func (br *bridge) current() *device.Conn {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.conn
+}
+
+func (br *bridge) Name() string { return "web player" }
+
+func (br *bridge) ID() spotify.ID {
+	if c := br.current(); c != nil {
+		return c.ID()
+	}
+	return ""
+}
+
+func (br *bridge) State() *device.State {
+	if c := br.current(); c != nil {
+		return c.State()
+	}
+	return nil
+}
+
+func (br *bridge) Do(e event.Event) bool {
+	if c := br.current(); c != nil {
+		return c.Do(e)
+	}
+	return false
+}
+
+func (br *bridge) Close() error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.ws != nil {
+		return br.ws.Close()
+	}
+	return nil
+}
+
+// showArt shows the cover as colored ASCII art on the page when it changed
+func (br *bridge) showArt(ws *websocket.Conn, url string) {
+	br.mu.Lock()
+	changed := url != "" && url != br.art
+	br.art = url
+	br.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	go func() {
+		path, err := fetchArt(ws.Request().Context(), url, br.cacheDir)
+		if err != nil {
+			logWebPlayer.Warn("error fetching cover", "err", err)
+			return
+		}
+		img, err := toAscii(path, 50, 25, true)
+		if err != nil {
+			logWebPlayer.Warn("error converting cover", "err", err)
+			return
+		}
+		m := device.Message{Event: "art", Data: map[string]any{"html": ansi.ToHTML(img)}}
+		if err := websocket.JSON.Send(ws, m); err != nil {
+			logWebPlayer.Warn("error sending cover to web player", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,191 @@
+// Package catalog reads the detail pages of artists and albums.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// maxReleases limits the discography of prolific artists
+const maxReleases = 200
+
+type Track struct {
+	ID       spotify.ID
+	URI      spotify.URI
+	Name     string
+	Artists  string
+	Number   int
+	Duration time.Duration
+}
+
+type ArtistSummary struct {
+	ID   spotify.ID
+	URI  spotify.URI
+	Name string
+}
+
+type AlbumSummary struct {
+	ID      spotify.ID
+	URI     spotify.URI
+	Name    string
+	Artists string
+	// Year is the year of the release
+	Year   string
+	Tracks int
+}
+
+type Artist struct {
+	ArtistSummary
+	Genres    []string
+	Followers int
+	TopTracks []Track
+	// the discography, grouped like on Spotify
+	Albums       []AlbumSummary
+	Singles      []AlbumSummary
+	Compilations []AlbumSummary
+	Related      []ArtistSummary
+}
+
+type Album struct {
+	AlbumSummary
+	ArtistList []ArtistSummary
+	Tracks     []Track
+}
+
+// Duration is the length of the whole album
+func (a Album) Duration() time.Duration {
+	var d time.Duration
+	for _, t := range a.Tracks {
+		d += t.Duration
+	}
+	return d
+}
+
+type Catalog struct {
+	c *spotify.Client
+}
+
+func New(c *spotify.Client) Catalog {
+	return Catalog{c}
+}
+
+// Artist loads the page of an artist. Related artists are left out if
+// the API doesn't offer them.
+func (c Catalog) Artist(ctx context.Context, id spotify.ID) (*Artist, error) {
+	full, err := c.c.GetArtist(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading artist %s: %s", id, err)
+	}
+	a := &Artist{
+		ArtistSummary: ArtistSummary{full.ID, full.URI, full.Name},
+		Genres:        full.Genres,
+		Followers:     int(full.Followers.Count),
+	}
+
+	top, err := c.c.GetArtistsTopTracks(ctx, id, spotify.MarketFromToken)
+	if err != nil {
+		return nil, fmt.Errorf("error loading top tracks of %s: %s", a.Name, err)
+	}
+	for i, t := range top {
+		a.TopTracks = append(a.TopTracks, trackOf(t.SimpleTrack, i+1))
+	}
+
+	if err := c.discography(ctx, a); err != nil {
+		return nil, err
+	}
+
+	// the endpoint is gone for newer apps, the page works without it
+	related, err := c.c.GetRelatedArtists(ctx, id)
+	if err == nil {
+		for _, r := range related {
+			a.Related = append(a.Related, ArtistSummary{r.ID, r.URI, r.Name})
+		}
+	}
+	return a, nil
+}
+
+func (c Catalog) discography(ctx context.Context, a *Artist) error {
+	types := []spotify.AlbumType{spotify.AlbumTypeAlbum, spotify.AlbumTypeSingle, spotify.AlbumTypeCompilation}
+	page, err := c.c.GetArtistAlbums(ctx, a.ID, types, spotify.Limit(50))
+	for n := 0; err == nil && n < maxReleases; {
+		for _, al := range page.Albums {
+			s := summaryOf(al)
+			switch al.AlbumGroup {
+			case "single":
+				a.Singles = append(a.Singles, s)
+			case "compilation":
+				a.Compilations = append(a.Compilations, s)
+			default:
+				a.Albums = append(a.Albums, s)
+			}
+		}
+		n += len(page.Albums)
+		err = c.c.NextPage(ctx, page)
+	}
+	if err != nil && !errors.Is(err, spotify.ErrNoMorePages) {
+		return fmt.Errorf("error loading albums of %s: %s", a.Name, err)
+	}
+	return nil
+}
+
+// Album loads an album with all of its tracks
+func (c Catalog) Album(ctx context.Context, id spotify.ID) (*Album, error) {
+	full, err := c.c.GetAlbum(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading album %s: %s", id, err)
+	}
+	a := &Album{AlbumSummary: summaryOf(full.SimpleAlbum)}
+	for _, ar := range full.Artists {
+		a.ArtistList = append(a.ArtistList, ArtistSummary{ar.ID, ar.URI, ar.Name})
+	}
+
+	page := &full.Tracks
+	for {
+		for _, t := range page.Tracks {
+			a.Tracks = append(a.Tracks, trackOf(t, int(t.TrackNumber)))
+		}
+		err := c.c.NextPage(ctx, page)
+		if errors.Is(err, spotify.ErrNoMorePages) {
+			return a, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error loading tracks of %s: %s", a.Name, err)
+		}
+	}
+}
+
+func trackOf(t spotify.SimpleTrack, number int) Track {
+	return Track{
+		ID:       t.ID,
+		URI:      t.URI,
+		Name:     t.Name,
+		Artists:  artistNames(t.Artists),
+		Number:   number,
+		Duration: t.TimeDuration(),
+	}
+}
+
+func summaryOf(a spotify.SimpleAlbum) AlbumSummary {
+	year, _, _ := strings.Cut(a.ReleaseDate, "-")
+	return AlbumSummary{
+		ID:      a.ID,
+		URI:     a.URI,
+		Name:    a.Name,
+		Artists: artistNames(a.Artists),
+		Year:    year,
+		Tracks:  int(a.TotalTracks),
+	}
+}
+
+func artistNames(as []spotify.SimpleArtist) string {
+	names := make([]string, 0, len(as))
+	for _, a := range as {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/config"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/history"
+	"github.com/moritz-tiesler/spoli/library"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/moritz-tiesler/spoli/playlist"
+	"github.com/moritz-tiesler/spoli/status"
+	"github.com/zmb3/spotify/v2"
+)
+
+// command is a CLI subcommand, e.g. `spoli history export`
+type command struct {
+	usage string
+	run   func(cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"history": {
+		usage: "history export [-format csv|json] [-range today|week|month|year|all] [-o file]",
+		run:   runHistory,
+	},
+	"playlist": {
+		usage: "playlist list|show ID|create NAME|rename ID NAME|add ID [TRACK_ID...]|remove ID POS...|move ID FROM TO|dedupe ID [-by id|isrc]",
+		run:   runPlaylist,
+	},
+	"play": {
+		usage: "play [-queue] spotify:KIND:ID|https://open.spotify.com/KIND/ID",
+		run:   runPlay,
+	},
+	"status": {
+		usage: "status [-follow] [-preset plain|tmux|waybar|i3bar] [-format TEMPLATE] [-interval 1s] [-width 30]",
+		run:   runStatus,
+	},
+	"save": {
+		usage: "save [track|album|show|episode]",
+		run:   func(cfg *config.Config, args []string) error { return runLibrary(cfg, true, args) },
+	},
+	"unsave": {
+		usage: "unsave [track|album|show|episode]",
+		run:   func(cfg *config.Config, args []string) error { return runLibrary(cfg, false, args) },
+	},
+}
+
+// runCommand runs the subcommand named by args[0].
+// It reports false if there is no such command.
+func runCommand(cfg *config.Config, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+	if err := cmd.run(cfg, args[1:]); err != nil {
+		return true, fmt.Errorf("%s\nusage: spoli %s", err, cmd.usage)
+	}
+	return true, nil
+}
+
+// login serves the auth callback until the user has logged in
+// and returns the authenticated client. Commands only log in if no
+// spoli is running, see viaDaemon.
+func login(cfg *config.Config) (*Client, error) {
+	if err := cfg.ValidateAuth(); err != nil {
+		return nil, err
+	}
+	auth = newAuth(cfg)
+	router := http.NewServeMux()
+	router.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		completeAuth(w, r)
+		fmt.Fprintln(w, "Login completed, you can close this window.")
+	})
+	s := &http.Server{Addr: cfg.Listen, Handler: router}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ListenAndServe()
+	}()
+	defer s.Shutdown(context.Background())
+
+	fmt.Fprintln(os.Stderr, "Please log in to Spotify by visiting the following page in your browser:", auth.AuthURL(state))
+	select {
+	case c := <-ch:
+		return newClient(c.c, c.h), nil
+	case err := <-errs:
+		return nil, fmt.Errorf("error starting server: %s", err)
+	}
+}
+
+func runHistory(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return fmt.Errorf("unknown history command")
+	}
+	fs := flag.NewFlagSet("history export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "export format, csv or json")
+	rngName := fs.String("range", "all", "time range to export")
+	out := fs.String("o", "", "output file, defaults to stdout")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	rng, ok := history.ParseRange(*rngName)
+	if !ok {
+		return fmt.Errorf("unknown range %q", *rngName)
+	}
+
+	store, err := history.Open(cfg.HistoryPath())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	now := time.Now()
+	plays, err := store.Range(rng.Since(now), now)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "csv":
+		return history.WriteCSV(w, plays)
+	case "json":
+		return history.WriteJSON(w, plays)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runPlaylist(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing playlist command")
+	}
+	sub, args := args[0], args[1:]
+
+	// check arguments before sending the user to log in
+	var want int
+	switch sub {
+	case "list":
+	case "show", "create", "add", "dedupe":
+		want = 1
+	case "rename", "remove":
+		want = 2
+	case "move":
+		want = 3
+	default:
+		return fmt.Errorf("unknown playlist command %q", sub)
+	}
+	if len(args) < want {
+		return fmt.Errorf("%s needs at least %d arguments", sub, want)
+	}
+	if ok, err := playlistViaDaemon(cfg, sub, args); ok {
+		return err
+	}
+
+	client, err := login(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	ed := playlist.NewEditor(client.Client)
+
+	switch sub {
+	case "list":
+		ps, err := ed.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			fmt.Printf("%s\t%d\t%s\n", p.ID, p.Tracks, p.Name)
+		}
+		return nil
+	case "create":
+		p, err := ed.Create(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(p.ID)
+		return nil
+	case "rename":
+		return ed.Rename(ctx, spotify.ID(args[0]), args[1])
+	case "add":
+		tracks := make([]spotify.ID, 0, len(args)-1)
+		for _, t := range args[1:] {
+			tracks = append(tracks, spotify.ID(t))
+		}
+		if len(tracks) == 0 {
+			track, err := client.currentTrack(ctx)
+			if err != nil {
+				return err
+			}
+			tracks = append(tracks, track.ID)
+		}
+		_, err := ed.Add(ctx, spotify.ID(args[0]), tracks...)
+		return err
+	}
+
+	// the remaining commands work on the loaded playlist
+	p, err := ed.Load(ctx, spotify.ID(args[0]))
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "show":
+		for _, it := range p.Items {
+			fmt.Printf("%d\t%s\t%s - %s\n", it.Position, it.ID, it.Artists, it.Name)
+		}
+	case "remove":
+		positions, err := atois(args[1:])
+		if err != nil {
+			return err
+		}
+		_, err = ed.Remove(ctx, p, positions...)
+		return err
+	case "move":
+		pos, err := atois(args[1:3])
+		if err != nil {
+			return err
+		}
+		_, err = ed.Move(ctx, p, pos[0], pos[1])
+		return err
+	case "dedupe":
+		fs := flag.NewFlagSet("playlist dedupe", flag.ContinueOnError)
+		byName := fs.String("by", "id", "compare tracks by id or isrc")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		by, ok := playlist.ParseDedupeKey(*byName)
+		if !ok {
+			return fmt.Errorf("unknown dedupe key %q", *byName)
+		}
+		n, _, err := ed.Dedupe(ctx, p, by)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %d duplicates\n", n)
+	}
+	return nil
+}
+
+// playlistViaDaemon runs the playlist command on the running spoli. It
+// reports false if there is none.
+func playlistViaDaemon(cfg *config.Config, sub string, args []string) (bool, error) {
+	var name string
+	var data map[string]string
+	switch sub {
+	case "list":
+		name = "playlists"
+	case "show":
+		name, data = "playlistOpen", map[string]string{"id": args[0]}
+	case "create":
+		name, data = "playlistCreate", map[string]string{"name": args[0]}
+	case "rename":
+		name, data = "playlistRename", map[string]string{"id": args[0], "name": args[1]}
+	case "add":
+		name, data = "playlistAddCurrent", map[string]string{"id": args[0], "tracks": strings.Join(args[1:], " ")}
+	case "remove":
+		if _, err := atois(args[1:]); err != nil {
+			return true, err
+		}
+		name, data = "playlistRemove", map[string]string{"id": args[0], "positions": strings.Join(args[1:], " ")}
+	case "move":
+		if _, err := atois(args[1:3]); err != nil {
+			return true, err
+		}
+		name, data = "playlistMove", map[string]string{"id": args[0], "from": args[1], "to": args[2]}
+	case "dedupe":
+		fs := flag.NewFlagSet("playlist dedupe", flag.ContinueOnError)
+		byName := fs.String("by", "id", "compare tracks by id or isrc")
+		if err := fs.Parse(args[1:]); err != nil {
+			return true, err
+		}
+		name, data = "playlistDedupe", map[string]string{"id": args[0], "by": *byName}
+	}
+
+	// the answer is the last update of the playlist, or of the list
+	var update playlistPayload
+	ok, err := viaDaemon(cfg, name, data, func(name, data string) {
+		if name != event.PLAYLIST_UPDATE.String() {
+			return
+		}
+		var u playlistPayload
+		if err := json.Unmarshal([]byte(data), &u); err != nil {
+			return
+		}
+		switch {
+		case sub == "list" && u.Playlists != nil,
+			sub == "create" && u.Created != "",
+			len(args) > 0 && u.Playlist != nil && string(u.Playlist.ID) == args[0]:
+			update = u
+		}
+	})
+	if !ok || err != nil {
+		return ok, err
+	}
+
+	switch sub {
+	case "list":
+		for _, p := range update.Playlists {
+			fmt.Printf("%s\t%d\t%s\n", p.ID, p.Tracks, p.Name)
+		}
+	case "show":
+		if update.Playlist == nil {
+			return true, fmt.Errorf("spoli didn't send the playlist")
+		}
+		for _, it := range update.Playlist.Items {
+			fmt.Printf("%d\t%s\t%s - %s\n", it.Position, it.ID, it.Artists, it.Name)
+		}
+	case "create":
+		fmt.Println(update.Created)
+	case "dedupe":
+		fmt.Println(update.Message)
+	}
+	return true, nil
+}
+
+// runPlay plays or queues a Spotify URI or link on the active device
+func runPlay(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("play", flag.ContinueOnError)
+	queue := fs.Bool("queue", false, "add the track or episode to the queue instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("play needs one URI or link")
+	}
+	l, err := link.Parse(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if _, err := l.Action(*queue); err != nil {
+		return err
+	}
+
+	data := map[string]string{"link": fs.Arg(0), "queue": strconv.FormatBool(*queue)}
+	ok, err := viaDaemon(cfg, "link", data, nil)
+	if !ok {
+		var client *Client
+		if client, err = login(cfg); err != nil {
+			return err
+		}
+		err = client.playLink(context.Background(), l, *queue)
+	}
+	if err != nil {
+		return err
+	}
+	verb := "playing"
+	if *queue {
+		verb = "queued"
+	}
+	fmt.Printf("%s %s\n", verb, l)
+	return nil
+}
+
+// statusTimeout is how long `spoli status` waits for the player state
+const statusTimeout = 3 * time.Second
+
+// runStatus prints the player state of the running spoli for status
+// bars, once or whenever it changes
+func runStatus(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	follow := fs.Bool("follow", false, "keep printing the status as it changes")
+	preset := fs.String("preset", "plain", "output for a status bar, plain, tmux, waybar or i3bar")
+	format := fs.String("format", "", "Go template of the text, e.g. '{{.Artist}} - {{.Title}} [{{.Progress}}]'")
+	interval := fs.Duration("interval", time.Second, "least time between two lines when following")
+	width := fs.Int("width", 30, "longer titles scroll when following, 0 turns scrolling off")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval < 100*time.Millisecond {
+		return fmt.Errorf("interval must be at least 100ms")
+	}
+	r, err := status.NewRenderer(*preset, *format)
+	if err != nil {
+		return err
+	}
+	url := cfg.APIURL() + "/api/events"
+	if *follow {
+		return followStatus(url, cfg.SessionPath(), r, *interval, *width)
+	}
+
+	token, err := os.ReadFile(cfg.SessionPath())
+	if err != nil {
+		return fmt.Errorf("spoli isn't running: %s", err)
+	}
+	// the stream starts with the current state. Right after spoli
+	// started there may be none yet, the status is "stopped" then rather
+	// than a status bar waiting for it.
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+	var line string
+	var renderErr error
+	err = status.Stream(ctx, url, string(token), func(s status.State) {
+		line, renderErr = r.Render(s.At(time.Now(), time.Now()))
+		cancel()
+	})
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		line, renderErr = r.Render(status.State{}.At(time.Now(), time.Now()))
+	case !errors.Is(err, context.Canceled):
+		return err
+	}
+	if renderErr != nil {
+		return renderErr
+	}
+	if h := r.Header(); h != "" {
+		fmt.Println(h)
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// followStatus prints a line per interval if it changed, the title
+// scrolls by a character each time. It reconnects when spoli restarts,
+// which creates a new session secret.
+func followStatus(url, sessionPath string, r *status.Renderer, interval time.Duration, width int) error {
+	var mu sync.Mutex
+	var st status.State
+	since := time.Now()
+	go func() {
+		for {
+			token, err := os.ReadFile(sessionPath)
+			if err == nil {
+				err = status.Stream(context.Background(), url, string(token), func(s status.State) {
+					mu.Lock()
+					defer mu.Unlock()
+					st, since = s, time.Now()
+				})
+			}
+			fmt.Fprintln(os.Stderr, "lost spoli, retrying:", err)
+			mu.Lock()
+			st = status.State{}
+			mu.Unlock()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	if h := r.Header(); h != "" {
+		fmt.Println(h)
+	}
+	var last, title string
+	offset := 0
+	for t := time.NewTicker(interval); ; <-t.C {
+		mu.Lock()
+		info := st.At(since, time.Now())
+		mu.Unlock()
+		if info.Title != title {
+			title, offset = info.Title, 0
+		}
+		info.Title = status.Scroll(info.Title, width, offset)
+		offset++
+		line, err := r.Render(info)
+		if err != nil {
+			return err
+		}
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+	}
+}
+
+// runLibrary saves or removes the current item, or the album or show
+// it belongs to.
+func runLibrary(cfg *config.Config, save bool, args []string) error {
+	var kind library.Kind
+	if len(args) > 0 {
+		var ok bool
+		if kind, ok = library.ParseKind(args[0]); !ok {
+			return fmt.Errorf("unknown kind %q", args[0])
+		}
+	}
+	verb, name := "saved", "librarySave"
+	if !save {
+		verb, name = "removed", "libraryRemove"
+	}
+	ok, err := viaDaemon(cfg, name, map[string]string{"kind": string(kind)}, nil)
+	if ok {
+		if err != nil {
+			return err
+		}
+		what := "the current item"
+		if kind != "" {
+			what = "the current " + string(kind)
+		}
+		fmt.Printf("%s %s\n", verb, what)
+		return nil
+	}
+
+	client, err := login(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	it, err := client.lib.Current(ctx)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return fmt.Errorf("nothing is playing")
+	}
+	if kind == "" {
+		kind = it.Kind
+	}
+	id, err := it.IDOf(kind)
+	if err != nil {
+		return err
+	}
+	if save {
+		err = client.lib.Save(ctx, kind, id)
+	} else {
+		err = client.lib.Remove(ctx, kind, id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s %s\n", verb, kind, id)
+	return nil
+}
+
+func atois(args []string) ([]int, error) {
+	ns := make([]int, 0, len(args))
+	for _, a := range args {
+		n, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid position %q", a)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
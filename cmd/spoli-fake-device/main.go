@@ -0,0 +1,118 @@
+// Command spoli-fake-device is a playback device that plays nothing. It
+// speaks the protocol of package device on stdin and stdout, to try
+// spoli's device handling without a real player:
+//
+//	spoli -device-command spoli-fake-device
+//
+// The fake never stops being the active device, it sends its state every
+// second even while paused. spoli therefore plays the commands the
+// device supports on the fake and not on other Spotify devices, see
+// Broker.drive.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/device"
+)
+
+var tracks = []string{"First Fake Song", "Second Fake Song", "Third Fake Song"}
+
+const duration = 3 * 60 * 1000
+
+type player struct {
+	mu       sync.Mutex
+	out      *json.Encoder
+	paused   bool
+	track    int
+	position int
+	volume   int
+}
+
+func (p *player) send(m device.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.out.Encode(m); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (p *player) state() device.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return device.Message{Event: "sdkState", Data: map[string]any{
+		"paused":   p.paused,
+		"position": p.position,
+		"duration": duration,
+		"volume":   p.volume,
+		"track":    tracks[p.track],
+	}}
+}
+
+func (p *player) handle(m device.Message) {
+	p.mu.Lock()
+	switch m.Event {
+	case "token":
+		tok, _ := m.Data["token"].(string)
+		log.Printf("got a token of %d characters", len(tok))
+		p.mu.Unlock()
+		return
+	case "tokenError":
+		log.Printf("no token: %v", m.Data["message"])
+		p.mu.Unlock()
+		return
+	case "togglePlay":
+		p.paused = !p.paused
+	case "next":
+		p.track, p.position = (p.track+1)%len(tracks), 0
+	case "prev":
+		p.track, p.position = (p.track+len(tracks)-1)%len(tracks), 0
+	case "volume":
+		v, _ := m.Data["volume"].(float64)
+		p.volume = int(v)
+	case "seek":
+		v, _ := m.Data["position"].(float64)
+		p.position = int(v)
+	default:
+		log.Printf("unknown command %q", m.Event)
+	}
+	p.mu.Unlock()
+	p.send(p.state())
+}
+
+func main() {
+	log.SetFlags(0)
+	name := os.Getenv("SPOLI_DEVICE_NAME")
+	p := &player{out: json.NewEncoder(os.Stdout), volume: 50}
+
+	p.send(device.Message{Event: "token"})
+	p.send(device.Message{Event: "sdkReady", Data: map[string]any{"device_id": "fake-" + name}})
+	p.send(p.state())
+
+	go func() {
+		for range time.Tick(time.Second) {
+			p.mu.Lock()
+			if !p.paused {
+				p.position = min(p.position+1000, duration)
+			}
+			p.mu.Unlock()
+			p.send(p.state())
+		}
+	}()
+
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var m device.Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			log.Printf("invalid message: %s", err)
+			continue
+		}
+		p.handle(m)
+	}
+	// spoli closed stdin, it wants us to quit
+}
@@ -0,0 +1,402 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of spoli. It is assembled in layers, each
+// overriding the previous one: defaults, the config file, environment
+// variables and command line flags.
+type Config struct {
+	// Listen is the address the local server listens on.
+	Listen string
+	// RedirectURL is the OAuth callback registered with Spotify. Its
+	// scheme and host are the origin of the web player.
+	RedirectURL  string
+	ClientID     string
+	ClientSecret string
+
+	LogFile  string
+	LogLevel string
+
+	// DataDir holds the listening history, CacheDir cached images.
+	DataDir  string
+	CacheDir string
+
+	// AllowedOrigins may call the local API from a browser, besides the
+	// origin of RedirectURL
+	AllowedOrigins []string
+
+	// DeviceCommand runs a playback device, see package device. Its
+	// arguments are split at spaces.
+	DeviceCommand []string
+	DeviceName    string
+
+	// StaticDir overrides the embedded web player files, for development
+	StaticDir string
+
+	KeymapFile   string
+	LayoutFile   string
+	PollInterval time.Duration
+	StatsRange   string
+
+	// Notifications shows a desktop notification for every new track
+	Notifications bool
+
+	// Theme is the name of the TUI's palette, builtin or from ThemeFile
+	Theme     string
+	ThemeFile string
+	// Background is "auto" to ask the terminal, "dark" or "light"
+	Background string
+	// Colors is the color profile, "auto" detects it and honors NO_COLOR
+	Colors string
+	// ArtAccent takes the accent color from the cover of the track
+	ArtAccent bool
+	// Mouse lets the TUI take clicks and the wheel, the terminal's own
+	// text selection then needs shift
+	Mouse bool
+}
+
+// option is one setting that can be configured in every layer
+type option struct {
+	key   string // in the config file, e.g. "log_file"
+	env   string // environment variable, e.g. "SPOLI_LOG_FILE"
+	flag  string // command line flag, empty if the option has none
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func str(f func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*f(c) = v
+		return nil
+	}
+}
+
+var options = []option{
+	{"listen", "SPOLI_LISTEN", "listen", "address of the local server, host:port", str(func(c *Config) *string { return &c.Listen })},
+	{"redirect_url", "SPOLI_REDIRECT_URL", "redirect-url", "OAuth redirect URL registered with Spotify", str(func(c *Config) *string { return &c.RedirectURL })},
+	{"client_id", "SPOLI_CLIENT_ID", "client-id", "Spotify client ID", str(func(c *Config) *string { return &c.ClientID })},
+	// secrets don't belong on the command line, where other users can see them
+	{"client_secret", "SPOLI_CLIENT_SECRET", "", "Spotify client secret", str(func(c *Config) *string { return &c.ClientSecret })},
+	{"log_file", "SPOLI_LOG_FILE", "log-file", "path of the log file", str(func(c *Config) *string { return &c.LogFile })},
+	{"log_level", "SPOLI_LOG_LEVEL", "log-level", "debug, info, warn or error", str(func(c *Config) *string { return &c.LogLevel })},
+	{"data_dir", "SPOLI_DATA_DIR", "data-dir", "directory of the listening history", str(func(c *Config) *string { return &c.DataDir })},
+	{"cache_dir", "SPOLI_CACHE_DIR", "cache-dir", "directory of cached images", str(func(c *Config) *string { return &c.CacheDir })},
+	{"allowed_origins", "SPOLI_ALLOWED_ORIGINS", "allowed-origins", "comma separated origins allowed to call the API from a browser", func(c *Config, v string) error {
+		c.AllowedOrigins = nil
+		for _, o := range strings.Split(v, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, strings.TrimSuffix(o, "/"))
+			}
+		}
+		return nil
+	}},
+	{"device_command", "SPOLI_DEVICE_COMMAND", "device-command", "command running a playback device, e.g. a librespot wrapper", func(c *Config, v string) error {
+		c.DeviceCommand = strings.Fields(v)
+		return nil
+	}},
+	{"device_name", "SPOLI_DEVICE_NAME", "device-name", "name the playback device registers with", str(func(c *Config) *string { return &c.DeviceName })},
+	{"static_dir", "SPOLI_STATIC_DIR", "static-dir", "serve the web player from this directory instead of the embedded files", str(func(c *Config) *string { return &c.StaticDir })},
+	{"keymap", "SPOLI_KEYMAP", "keymap", "path of the keymap file", str(func(c *Config) *string { return &c.KeymapFile })},
+	{"layout", "SPOLI_LAYOUT", "layout", "path of the file the TUI's pane layout is saved in", str(func(c *Config) *string { return &c.LayoutFile })},
+	{"poll_interval", "SPOLI_POLL_INTERVAL", "poll-interval", "how often to poll the player state, e.g. 1s", func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		c.PollInterval = d
+		return nil
+	}},
+	{"stats_range", "SPOLI_STATS_RANGE", "stats-range", "initial range of the stats view", str(func(c *Config) *string { return &c.StatsRange })},
+	{"notifications", "SPOLI_NOTIFICATIONS", "notifications", "show a desktop notification for every new track, true or false", func(c *Config, v string) error {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		c.Notifications = on
+		return nil
+	}},
+	{"theme", "SPOLI_THEME", "theme", "name of the TUI's color palette", str(func(c *Config) *string { return &c.Theme })},
+	{"theme_file", "SPOLI_THEME_FILE", "theme-file", "path of the file with custom palettes", str(func(c *Config) *string { return &c.ThemeFile })},
+	{"background", "SPOLI_BACKGROUND", "background", "terminal background, auto, dark or light", str(func(c *Config) *string { return &c.Background })},
+	{"colors", "SPOLI_COLORS", "colors", "colors of the TUI, auto, none, 16, 256 or truecolor", str(func(c *Config) *string { return &c.Colors })},
+	{"art_accent", "SPOLI_ART_ACCENT", "art-accent", "take the accent color from the cover, true or false", func(c *Config, v string) error {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		c.ArtAccent = on
+		return nil
+	}},
+	{"mouse", "SPOLI_MOUSE", "mouse", "use the mouse in the TUI, true or false", func(c *Config, v string) error {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		c.Mouse = on
+		return nil
+	}},
+}
+
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+var StatsRanges = []string{"today", "week", "month", "year", "all"}
+
+var Backgrounds = []string{"auto", "dark", "light"}
+
+var ColorModes = []string{"auto", "none", "16", "256", "truecolor"}
+
+func Default() *Config {
+	return &Config{
+		// only this machine can reach the server, use e.g. 0.0.0.0:8080
+		// to control spoli from a phone
+		Listen:       "127.0.0.1:8080",
+		RedirectURL:  "http://127.0.0.1:8080/callback",
+		ClientID:     os.Getenv("SPOTIFY_ID"),
+		ClientSecret: os.Getenv("SPOTIFY_SECRET"),
+		LogFile:      "/tmp/spoli.logs",
+		LogLevel:     "info",
+		DataDir:      xdgDir("XDG_DATA_HOME", ".local/share"),
+		CacheDir:     xdgDir("XDG_CACHE_HOME", ".cache"),
+		KeymapFile:   filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "keys.conf"),
+		LayoutFile:   filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "layout.conf"),
+		DeviceName:   "spoli",
+		PollInterval: time.Second,
+		StatsRange:   "week",
+		Theme:        "default",
+		ThemeFile:    filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "themes.conf"),
+		Background:   "auto",
+		Colors:       "auto",
+		Mouse:        true,
+	}
+}
+
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return filepath.Join(dir, "spoli")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "spoli")
+	}
+	return filepath.Join(home, fallback, "spoli")
+}
+
+// Path is the default location of the config file.
+func Path() string {
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "config")
+}
+
+// Load builds the configuration from all layers. args are the command
+// line arguments without the program name, the arguments following
+// the flags are returned.
+func Load(args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("spoli", flag.ContinueOnError)
+	path := fs.String("config", "", "path of the config file (env SPOLI_CONFIG)")
+	flags := map[string]string{}
+	for _, o := range options {
+		if o.flag == "" {
+			continue
+		}
+		fs.Func(o.flag, fmt.Sprintf("%s (env %s)", o.usage, o.env), func(v string) error {
+			flags[o.key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	c := Default()
+
+	file := *path
+	if file == "" {
+		file = os.Getenv("SPOLI_CONFIG")
+	}
+	explicit := file != ""
+	if !explicit {
+		file = Path()
+	}
+	f, err := os.Open(file)
+	switch {
+	case err == nil:
+		err = c.read(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading config %s: %s", file, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// no config file is fine
+	default:
+		return nil, nil, fmt.Errorf("error opening config: %s", err)
+	}
+
+	for _, o := range options {
+		if v, ok := os.LookupEnv(o.env); ok {
+			if err := o.set(c, v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s", o.env, err)
+			}
+		}
+	}
+	for _, o := range options {
+		if v, ok := flags[o.key]; ok {
+			if err := o.set(c, v); err != nil {
+				return nil, nil, fmt.Errorf("-%s: %s", o.flag, err)
+			}
+		}
+	}
+	return c, fs.Args(), nil
+}
+
+// read applies a config file of "key = value" lines. Lines starting
+// with # are comments.
+func (c *Config) read(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected 'key = value'", n)
+		}
+		k, v = strings.TrimSpace(k), strings.Trim(strings.TrimSpace(v), `"`)
+		i := slices.IndexFunc(options, func(o option) bool { return o.key == k })
+		if i < 0 {
+			return fmt.Errorf("line %d: unknown key %q", n, k)
+		}
+		if err := options[i].set(c, v); err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// Validate checks the settings every command needs.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, port, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %q is not a host:port address", c.Listen))
+	} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		errs = append(errs, fmt.Errorf("listen: invalid port %q", port))
+	}
+
+	u, err := url.Parse(c.RedirectURL)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("redirect_url: %s", err))
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		errs = append(errs, fmt.Errorf("redirect_url: %q is not an absolute http(s) URL", c.RedirectURL))
+	case u.Path != "/callback":
+		errs = append(errs, fmt.Errorf("redirect_url: path must be /callback, got %q", u.Path))
+	default:
+		_, listenPort, _ := net.SplitHostPort(c.Listen)
+		if port := u.Port(); port != "" && listenPort != "" && port != listenPort {
+			errs = append(errs, fmt.Errorf("redirect_url: port %s does not match listen port %s", port, listenPort))
+		}
+	}
+
+	for _, o := range c.AllowedOrigins {
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("allowed_origins: %q is not an origin like http://host:port", o))
+		}
+	}
+
+	if !slices.Contains(LogLevels, c.LogLevel) {
+		errs = append(errs, fmt.Errorf("log_level: must be one of %s", strings.Join(LogLevels, ", ")))
+	}
+	if c.LogFile == "" {
+		errs = append(errs, fmt.Errorf("log_file: must not be empty"))
+	}
+	if c.DataDir == "" || c.CacheDir == "" {
+		errs = append(errs, fmt.Errorf("data_dir and cache_dir must not be empty"))
+	}
+	if c.PollInterval < 200*time.Millisecond {
+		errs = append(errs, fmt.Errorf("poll_interval: must be at least 200ms"))
+	}
+	if !slices.Contains(StatsRanges, c.StatsRange) {
+		errs = append(errs, fmt.Errorf("stats_range: must be one of %s", strings.Join(StatsRanges, ", ")))
+	}
+	if !slices.Contains(Backgrounds, c.Background) {
+		errs = append(errs, fmt.Errorf("background: must be one of %s", strings.Join(Backgrounds, ", ")))
+	}
+	if !slices.Contains(ColorModes, c.Colors) {
+		errs = append(errs, fmt.Errorf("colors: must be one of %s", strings.Join(ColorModes, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateAuth checks the settings needed to log in to Spotify.
+func (c *Config) ValidateAuth() error {
+	var errs []error
+	if c.ClientID == "" {
+		errs = append(errs, fmt.Errorf("client_id: not set, use the config file, -client-id, SPOLI_CLIENT_ID or SPOTIFY_ID"))
+	}
+	if c.ClientSecret == "" {
+		errs = append(errs, fmt.Errorf("client_secret: not set, use the config file, SPOLI_CLIENT_SECRET or SPOTIFY_SECRET"))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateServer checks the settings needed to run the player, on top
+// of ValidateAuth.
+func (c *Config) ValidateServer() error {
+	errs := []error{c.ValidateAuth()}
+	if fi, err := os.Stat(c.StaticDir); c.StaticDir != "" && (err != nil || !fi.IsDir()) {
+		errs = append(errs, fmt.Errorf("static_dir: %q is not a directory", c.StaticDir))
+	}
+	return errors.Join(errs...)
+}
+
+// Origin is the scheme and host the web player is served from.
+func (c *Config) Origin() string {
+	u, err := url.Parse(c.RedirectURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Origins are the origins allowed to call the local API from a browser
+func (c *Config) Origins() []string {
+	return append([]string{c.Origin()}, c.AllowedOrigins...)
+}
+
+func (c *Config) HistoryPath() string {
+	return filepath.Join(c.DataDir, "history.db")
+}
+
+// SessionPath holds the session secret of the running spoli, for
+// commands like `spoli status` that talk to it
+func (c *Config) SessionPath() string {
+	return filepath.Join(c.DataDir, "session")
+}
+
+// APIURL is the base URL of the local API, e.g. http://127.0.0.1:8080
+func (c *Config) APIURL() string {
+	host, port, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return ""
+	}
+	switch host {
+	case "", "0.0.0.0", "::":
+		// listening everywhere, localhost included
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
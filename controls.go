@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/zmb3/spotify/v2"
+)
+
+func (c Client) setVolume(ctx context.Context, ps *spotify.PlayerState, arg string) error {
+	n, relative, err := event.ParseRelative(arg)
+	if err != nil {
+		return err
+	}
+	if relative {
+		n += int(ps.Device.Volume)
+	}
+	return c.Volume(ctx, min(max(n, 0), 100))
+}
+
+// seek takes seconds, relative values are added to the current progress
+func (c Client) seek(ctx context.Context, ps *spotify.PlayerState, arg string) error {
+	n, relative, err := event.ParseRelative(arg)
+	if err != nil {
+		return err
+	}
+	ms := n * 1000
+	if relative {
+		ms += int(ps.Progress)
+	}
+	return c.Seek(ctx, max(ms, 0))
+}
+
+func (c Client) setShuffle(ctx context.Context, ps *spotify.PlayerState, state string) error {
+	on := !ps.ShuffleState
+	switch state {
+	case "on":
+		on = true
+	case "off":
+		on = false
+	}
+	return c.Shuffle(ctx, on)
+}
+
+var repeatStates = []string{"off", "context", "track"}
+
+func (c Client) setRepeat(ctx context.Context, ps *spotify.PlayerState, state string) error {
+	if state == "" {
+		state = repeatStates[0]
+		for i, s := range repeatStates {
+			if s == ps.RepeatState {
+				state = repeatStates[(i+1)%len(repeatStates)]
+			}
+		}
+	}
+	return c.Repeat(ctx, state)
+}
+
+// handleDeviceEvent lists devices or transfers playback. Neither needs
+// an active device.
+func (c Client) handleDeviceEvent(ctx context.Context, e event.Event, b Broker) error {
+	devices, err := c.PlayerDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing devices: %s", err)
+	}
+
+	switch e.(type) {
+	case event.Devices:
+		names := make([]string, 0, len(devices))
+		for _, d := range devices {
+			names = append(names, d.Name)
+		}
+		b.publish(event.New(event.DEVICE_LIST, map[any]any{"devices": names}))
+	case event.Device:
+		d, err := findDevice(devices, e.Data()["name"].(string))
+		if err != nil {
+			return err
+		}
+		return c.TransferPlayback(ctx, d.ID, true)
+	}
+	return nil
+}
+
+// findDevice picks the device with the given name, or the only one
+// whose name contains it, ignoring case.
+func findDevice(devices []spotify.PlayerDevice, name string) (spotify.PlayerDevice, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	var matches []spotify.PlayerDevice
+	for _, d := range devices {
+		if d.Restricted {
+			continue
+		}
+		dn := strings.ToLower(d.Name)
+		if dn == name {
+			return d, nil
+		}
+		if strings.Contains(dn, name) {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return spotify.PlayerDevice{}, fmt.Errorf("no device named %q", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return spotify.PlayerDevice{}, fmt.Errorf("%d devices match %q", len(matches), name)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/config"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/status"
+)
+
+// daemonTimeout is how long the CLI waits for the running spoli to
+// answer a command
+const daemonTimeout = 30 * time.Second
+
+// daemon is a running spoli. The CLI sends its commands there, so they
+// need no login of their own and don't compete for the listen address.
+type daemon struct {
+	url   string // of the API
+	token string // the session secret
+}
+
+// viaDaemon sends the command to the running spoli and waits for its
+// result. f is passed the events until then, e.g. the playlists that
+// playlists lists. It reports false if no spoli is running, the command
+// needs a login then.
+func viaDaemon(cfg *config.Config, name string, data map[string]string, f func(name, data string)) (bool, error) {
+	token, err := os.ReadFile(cfg.SessionPath())
+	if err != nil {
+		return false, nil
+	}
+	d := daemon{url: cfg.APIURL(), token: string(token)}
+	err = d.do(name, data, f)
+	// the session of a spoli that didn't shut down cleanly
+	if errors.Is(err, status.ErrUnreachable) {
+		return false, nil
+	}
+	return true, err
+}
+
+func (d daemon) do(name string, data map[string]string, f func(name, data string)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), daemonTimeout)
+	defer cancel()
+	// the result follows on the events, they are opened first so it
+	// isn't missed
+	events, err := status.Connect(ctx, d.url+"/api/events", d.token)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+	id, err := d.post(ctx, name, data)
+	if err != nil {
+		return err
+	}
+	for {
+		kind, payload, err := events.Next()
+		if ctx.Err() != nil {
+			return fmt.Errorf("no answer from spoli: %s", ctx.Err())
+		}
+		if err != nil {
+			return err
+		}
+		if kind != event.RESULT.String() {
+			if f != nil {
+				f(kind, payload)
+			}
+			continue
+		}
+		var r struct {
+			ID    string `json:"id"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(payload), &r); err != nil {
+			return fmt.Errorf("error decoding result: %s", err)
+		}
+		if r.ID != id {
+			continue
+		}
+		if r.Error != "" {
+			return errors.New(r.Error)
+		}
+		return nil
+	}
+}
+
+// post queues the command and returns its ID
+func (d daemon) post(ctx context.Context, name string, data map[string]string) (string, error) {
+	body, err := json.Marshal(remoteCommand{Event: name, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("error encoding command: %s", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url+"/api/command", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error sending command: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending command: %s", err)
+	}
+	defer resp.Body.Close()
+	var answer struct {
+		ID    string `json:"id"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("error sending command: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("spoli refused the command: %s", answer.Error)
+	}
+	return answer.ID, nil
+}
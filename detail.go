@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moritz-tiesler/spoli/catalog"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/zmb3/spotify/v2"
+)
+
+// handleDetailEvent loads the page of an artist or album, published as
+// a DETAIL event, or plays an item from one
+func (c Client) handleDetailEvent(ctx context.Context, e event.Event, b Broker) error {
+	d := e.Data()
+	if _, ok := e.(event.Play); ok {
+		return c.play(ctx, d)
+	}
+
+	uri := d["uri"].(spotify.URI)
+	update := map[any]any{"uri": uri}
+	cat := catalog.New(c.Client)
+	l, err := link.Parse(string(uri))
+	switch {
+	case err != nil:
+	case l.Kind == link.ARTIST:
+		var a *catalog.Artist
+		if a, err = cat.Artist(ctx, l.ID); err == nil {
+			update["artist"] = a
+		}
+	case l.Kind == link.ALBUM:
+		var a *catalog.Album
+		if a, err = cat.Album(ctx, l.ID); err == nil {
+			update["album"] = a
+		}
+	default:
+		err = fmt.Errorf("can't show %s, only artists and albums", uri)
+	}
+	// a newer read publishes instead
+	if superseded(ctx) {
+		return ctx.Err()
+	}
+	if err != nil {
+		update["error"] = err
+	}
+	b.publish(event.New(event.DETAIL, update))
+	return err
+}
+
+// play plays "item" in "context", or among "uris" if there is no
+// context, e.g. an artist's top tracks
+func (c Client) play(ctx context.Context, d map[any]any) error {
+	item, _ := d["item"].(spotify.URI)
+	opts := &spotify.PlayOptions{}
+	if context, ok := d["context"].(spotify.URI); ok && context != "" {
+		opts.PlaybackContext = &context
+	} else {
+		opts.URIs, _ = d["uris"].([]spotify.URI)
+	}
+	if item != "" {
+		opts.PlaybackOffset = &spotify.PlaybackOffset{URI: item}
+	}
+	if err := c.PlayOpt(ctx, opts); err != nil {
+		return fmt.Errorf("error playing %s: %s", item, err)
+	}
+	return nil
+}
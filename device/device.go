@@ -0,0 +1,213 @@
+// Package device runs playback devices for spoli, so playing music
+// doesn't depend on another Spotify app being open.
+//
+// Devices speak a small protocol of JSON messages like
+//
+//	{"event": "sdkReady", "data": {"device_id": "..."}}
+//
+// The device sends "token" to ask for an access token and publishes
+// "sdkReady" {device_id}, "sdkNotReady", "sdkState" {paused, position,
+// duration, volume, track, image} or without data when it stopped
+// being the active device, and "sdkError" {type, message}. spoli answers
+// with "token" {token} or "tokenError" {message} and sends the commands
+// "togglePlay", "next", "prev", "volume" {volume} in percent and "seek"
+// {position} in ms.
+package device
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/zmb3/spotify/v2"
+)
+
+// PlaybackDevice is a Spotify Connect device run by spoli, e.g. the Web
+// Playback SDK in a browser or an external player process.
+type PlaybackDevice interface {
+	Name() string
+	// ID is the Spotify device ID, empty until the device is registered
+	ID() spotify.ID
+	// State is the last state, nil while the device isn't playing
+	State() *State
+	// Do plays e if the device is the active one and reports whether it
+	// did. TOGGLE_PLAY, NEXT, PREV, VOLUME and SEEK are supported.
+	Do(e event.Event) bool
+	Close() error
+}
+
+// Host is what devices need from spoli
+type Host interface {
+	// Token returns a valid access token, waiting for the login
+	Token() (string, error)
+	// Publish passes the events of the device to the broker
+	Publish(e event.Event)
+}
+
+// Message is sent both ways between spoli and a device
+type Message struct {
+	Event string         `json:"event"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// State is the part of a device's player state spoli needs
+type State struct {
+	Paused   bool
+	Position int // ms
+	Duration int // ms
+	Volume   int // percent
+	Track    string
+	Image    string
+}
+
+// ParseState reads the data of an sdkState message
+func ParseState(data map[string]any) *State {
+	if data == nil {
+		return nil
+	}
+	st := &State{
+		Paused:   data["paused"] == true,
+		Position: number(data["position"]),
+		Duration: number(data["duration"]),
+		Volume:   number(data["volume"]),
+	}
+	st.Track, _ = data["track"].(string)
+	st.Image, _ = data["image"].(string)
+	return st
+}
+
+// number reads a JSON number, which decodes to float64
+func number(v any) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+// Command translates e to the message for a device in state st.
+// Relative volumes and positions are resolved against st.
+func Command(e event.Event, st *State) (Message, bool) {
+	m := Message{Event: e.String()}
+	switch e.(type) {
+	case event.TogglePlay:
+		// a device that is already playing or paused has nothing to do,
+		// the broker's fallback finds the state as wanted
+		switch e.Data()["state"] {
+		case "play":
+			if !st.Paused {
+				return m, false
+			}
+		case "pause":
+			if st.Paused {
+				return m, false
+			}
+		}
+	case event.Next, event.Prev:
+	case event.Volume:
+		n, relative, err := event.ParseRelative(e.Data()["volume"].(string))
+		if err != nil {
+			return m, false
+		}
+		if relative {
+			n += st.Volume
+		}
+		m.Data = map[string]any{"volume": min(max(n, 0), 100)}
+	case event.Seek:
+		n, relative, err := event.ParseRelative(e.Data()["position"].(string))
+		if err != nil {
+			return m, false
+		}
+		ms := n * 1000
+		if relative {
+			ms += st.Position
+		}
+		m.Data = map[string]any{"position": min(max(ms, 0), st.Duration)}
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// Conn is the state of a device on the other end of a connection. It
+// handles the messages the device sends, the transport is up to the
+// implementation.
+type Conn struct {
+	host Host
+	send func(Message) error
+
+	mu    sync.Mutex
+	id    spotify.ID
+	state *State
+}
+
+// NewConn returns the Conn of a device that is sent messages with send.
+// send must be safe for concurrent use.
+func NewConn(host Host, send func(Message) error) *Conn {
+	return &Conn{host: host, send: send}
+}
+
+// Receive handles a message from the device
+func (c *Conn) Receive(m Message) error {
+	switch m.Event {
+	case "token":
+		// the device asks whenever its token expired, so it always gets
+		// a fresh one. Waiting for the login must not block the caller.
+		go func() {
+			tok, err := c.host.Token()
+			if err != nil {
+				c.send(Message{Event: "tokenError", Data: map[string]any{"message": err.Error()}})
+				return
+			}
+			c.send(Message{Event: "token", Data: map[string]any{"token": tok}})
+		}()
+	case event.SDK_READY.String():
+		id, _ := m.Data["device_id"].(string)
+		c.set(spotify.ID(id), nil)
+		c.host.Publish(event.New(event.SDK_READY, map[any]any{"device": id}))
+	case event.SDK_NOT_READY.String():
+		c.set("", nil)
+		c.host.Publish(event.New(event.SDK_NOT_READY, nil))
+	case event.SDK_STATE.String():
+		st := ParseState(m.Data)
+		c.mu.Lock()
+		c.state = st
+		c.mu.Unlock()
+		c.host.Publish(event.New(event.SDK_STATE, map[any]any{"state": st}))
+	case event.SDK_ERROR.String():
+		kind, _ := m.Data["type"].(string)
+		msg, _ := m.Data["message"].(string)
+		c.host.Publish(event.New(event.SDK_ERROR, map[any]any{"type": kind, "message": msg}))
+	default:
+		return fmt.Errorf("unknown message %q", m.Event)
+	}
+	return nil
+}
+
+func (c *Conn) set(id spotify.ID, st *State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.id, c.state = id, st
+}
+
+func (c *Conn) ID() spotify.ID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.id
+}
+
+func (c *Conn) State() *State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Do sends e to the device if it is playing
+func (c *Conn) Do(e event.Event) bool {
+	st := c.State()
+	if st == nil {
+		return false
+	}
+	m, ok := Command(e, st)
+	if !ok {
+		return false
+	}
+	return c.send(m) == nil
+}
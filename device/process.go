@@ -0,0 +1,134 @@
+package device
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/logging"
+)
+
+// Process is a device run as an external process, e.g. a wrapper around
+// a librespot-style daemon. It speaks the protocol as JSON lines on its
+// stdin and stdout, stderr goes to the log.
+type Process struct {
+	*Conn
+	name string
+	cmd  *exec.Cmd
+	log  *slog.Logger
+
+	mu    sync.Mutex // guards stdin
+	stdin io.WriteCloser
+	done  chan struct{}
+}
+
+// StartProcess starts the device command. The name is passed in the
+// SPOLI_DEVICE_NAME environment variable for the device to register
+// itself with.
+func StartProcess(ctx context.Context, host Host, name string, command []string) (*Process, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("error starting device: no command")
+	}
+	p := &Process{
+		name: name,
+		done: make(chan struct{}),
+		log:  logging.For("device").With("device", name),
+	}
+	p.Conn = NewConn(host, p.send)
+
+	p.cmd = exec.CommandContext(ctx, command[0], command[1:]...)
+	p.cmd.Env = append(p.cmd.Environ(), "SPOLI_DEVICE_NAME="+name)
+	p.cmd.Stderr = logWriter{p.log}
+	// give the device a chance to shut down on its own
+	p.cmd.Cancel = p.stop
+	p.cmd.WaitDelay = 5 * time.Second
+
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting device: %s", err)
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting device: %s", err)
+	}
+	p.stdin = stdin
+	if err := p.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting device: %s", err)
+	}
+
+	go func() {
+		defer close(p.done)
+		p.read(stdout)
+		err := p.cmd.Wait()
+		p.log.Info("device exited", "err", err)
+		if p.ID() != "" {
+			p.set("", nil)
+			host.Publish(event.New(event.SDK_NOT_READY, nil))
+		}
+	}()
+	return p, nil
+}
+
+func (p *Process) read(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		var m Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			p.log.Warn("invalid message", "err", err)
+			continue
+		}
+		if err := p.Receive(m); err != nil {
+			p.log.Warn("error handling message", "err", err)
+		}
+	}
+}
+
+func (p *Process) send(m Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.stdin.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("error sending %s to device %s: %s", m.Event, p.name, err)
+	}
+	return nil
+}
+
+func (p *Process) Name() string { return p.name }
+
+// stop asks the device to quit by closing its stdin
+func (p *Process) stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stdin.Close()
+}
+
+// Close stops the device and waits for it to exit
+func (p *Process) Close() error {
+	p.stop()
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+	return nil
+}
+
+// logWriter logs what the device writes to stderr
+type logWriter struct{ log *slog.Logger }
+
+func (w logWriter) Write(b []byte) (int, error) {
+	w.log.Info("device output", "output", strings.TrimRight(string(b), "\n"))
+	return len(b), nil
+}
@@ -0,0 +1,98 @@
+package device
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/event"
+)
+
+type fakeHost struct {
+	events chan event.Event
+}
+
+func (h fakeHost) Token() (string, error) { return "token", nil }
+func (h fakeHost) Publish(e event.Event)  { h.events <- e }
+
+// fakeDevice builds cmd/spoli-fake-device
+func fakeDevice(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	bin := filepath.Join(t.TempDir(), "spoli-fake-device")
+	out, err := exec.Command("go", "build", "-o", bin, "github.com/moritz-tiesler/spoli/cmd/spoli-fake-device").CombinedOutput()
+	if err != nil {
+		t.Fatalf("error building the fake device: %s\n%s", err, out)
+	}
+	return bin
+}
+
+// await returns the first event of kind k whose data passes ok
+func await(t *testing.T, events <-chan event.Event, k event.Kind, ok func(map[any]any) bool) event.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.String() == k.String() && ok(e.Data()) {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", k)
+		}
+	}
+}
+
+func track(name string) func(map[any]any) bool {
+	return func(d map[any]any) bool {
+		st, _ := d["state"].(*State)
+		return st != nil && st.Track == name
+	}
+}
+
+func always(map[any]any) bool { return true }
+
+func TestProcess(t *testing.T) {
+	bin := fakeDevice(t)
+	host := fakeHost{events: make(chan event.Event, 100)}
+	p, err := StartProcess(context.Background(), host, "test", []string{bin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	e := await(t, host.events, event.SDK_READY, always)
+	if id := e.Data()["device"]; id != "fake-test" {
+		t.Errorf("device id = %v, want fake-test", id)
+	}
+	await(t, host.events, event.SDK_STATE, track("First Fake Song"))
+	if p.ID() != "fake-test" {
+		t.Errorf("ID() = %q, want fake-test", p.ID())
+	}
+
+	if !p.Do(event.New(event.NEXT, nil)) {
+		t.Fatal("Do(NEXT) = false, want true")
+	}
+	await(t, host.events, event.SDK_STATE, track("Second Fake Song"))
+
+	if !p.Do(event.New(event.VOLUME, map[any]any{"volume": "+10"})) {
+		t.Fatal("Do(VOLUME) = false, want true")
+	}
+	await(t, host.events, event.SDK_STATE, func(d map[any]any) bool {
+		st, _ := d["state"].(*State)
+		return st != nil && st.Volume == 60
+	})
+	if p.Do(event.New(event.SHUFFLE, map[any]any{"state": "true"})) {
+		t.Error("Do(SHUFFLE) = true, the device can't shuffle")
+	}
+
+	p.Close()
+	await(t, host.events, event.SDK_NOT_READY, always)
+	if p.State() != nil {
+		t.Error("State() isn't nil after the device exited")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/zmb3/spotify/v2"
+)
+
+var logDevices = logging.For("devices")
+
+// deviceHost connects the playback devices spoli runs to the broker
+type deviceHost struct {
+	b *Broker
+
+	mu     sync.Mutex
+	tokens *tokenProvider
+	ready  chan struct{} // closed once logged in
+}
+
+func newDeviceHost(b *Broker) *deviceHost {
+	return &deviceHost{b: b, ready: make(chan struct{})}
+}
+
+// setTokens lets devices get access tokens, they may be waiting for them
+func (h *deviceHost) setTokens(p *tokenProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = p
+	select {
+	case <-h.ready:
+	default:
+		close(h.ready)
+	}
+}
+
+func (h *deviceHost) Token() (string, error) {
+	select {
+	case <-h.ready:
+	case <-h.b.ctx.Done():
+		return "", h.b.ctx.Err()
+	}
+	h.mu.Lock()
+	p := h.tokens
+	h.mu.Unlock()
+	tok, err := p.Token()
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// Publish passes device events to the broker's source. Playback moves
+// to a device as soon as it is ready.
+func (h *deviceHost) Publish(e event.Event) {
+	h.b.publish(e)
+	if _, ok := e.(event.SdkReady); !ok {
+		return
+	}
+	id, _ := e.Data()["device"].(string)
+	go func() {
+		select {
+		case <-h.ready:
+		case <-h.b.ctx.Done():
+			return
+		}
+		if err := h.b.spotifyClient().TransferPlayback(h.b.ctx, spotify.ID(id), true); err != nil {
+			logDevices.Error("error transferring playback", "device", id, "err", err)
+		}
+	}()
+}
+
+// drive plays e on the first of spoli's own devices that is playing
+// and reports whether one did. A paused device counts as playing until
+// it sends a state without data.
+func (b *Broker) drive(e event.Event) bool {
+	for _, d := range b.devices {
+		if d.Do(e) {
+			// a merged NEXT or PREV skips more than once
+			for range repeats(e) - 1 {
+				d.Do(e)
+			}
+			logDevices.Debug("played on device", "event", e, "device", d.Name())
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Broker) closeDevices() {
+	for _, d := range b.devices {
+		if err := d.Close(); err != nil {
+			logDevices.Warn("error closing device", "device", d.Name(), "err", err)
+		}
+	}
+}
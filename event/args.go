@@ -0,0 +1,27 @@
+package event
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseRelative parses the value of a VOLUME or SEEK event. Values
+// starting with + or - are relative to the current value, "m:ss" is
+// an absolute position in seconds.
+func ParseRelative(s string) (n int, relative bool, err error) {
+	relative = strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-")
+	if m, sec, ok := strings.Cut(s, ":"); ok && !relative {
+		mins, err1 := strconv.Atoi(m)
+		secs, err2 := strconv.Atoi(sec)
+		if err1 != nil || err2 != nil || mins < 0 || secs < 0 || secs > 59 {
+			return 0, false, fmt.Errorf("invalid position %q", s)
+		}
+		return mins*60 + secs, false, nil
+	}
+	n, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value %q, expected a number, +n or -n", s)
+	}
+	return n, relative, nil
+}
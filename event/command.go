@@ -0,0 +1,27 @@
+package event
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Command is an event sent to the broker's Sink with an ID. The broker
+// answers every command with a RESULT event carrying the same ID, the
+// command's name and an error message, empty if it succeeded.
+type Command struct {
+	Event
+	ID string
+}
+
+var lastID atomic.Uint64
+
+// NewCommand wraps e with a new ID. The prefix names the sender, e.g.
+// "tui", so it can pick out the results of its own commands.
+func NewCommand(prefix string, e Event) Command {
+	return Command{Event: e, ID: fmt.Sprintf("%s-%d", prefix, lastID.Add(1))}
+}
+
+// NewResult returns the RESULT event for the command with the id
+func NewResult(id, command, err string) Event {
+	return New(RESULT, map[any]any{"id": id, "command": command, "error": err})
+}
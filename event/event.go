@@ -7,32 +7,124 @@ type Event interface {
 
 type event int
 
+// Kind is the kind of an event, e.g. NEXT. It allows other packages
+// to refer to kinds without constructing events.
+type Kind = event
+
 const (
 	UKNOWN event = iota
 	TOGGLE_PLAY
 	NEXT
 	PREV
 	SONGCHANGE
+	STATECHANGE
+	PLAYLISTS
+	PLAYLIST_OPEN
+	PLAYLIST_CREATE
+	PLAYLIST_RENAME
+	PLAYLIST_ADD_CURRENT
+	PLAYLIST_REMOVE
+	PLAYLIST_MOVE
+	PLAYLIST_DEDUPE
+	PLAYLIST_UPDATE
+	LIBRARY_SAVE
+	LIBRARY_REMOVE
+	LIBRARY_STATE
+	VOLUME
+	SEEK
+	DEVICE
+	SHUFFLE
+	REPEAT
+	DEVICES
+	DEVICE_LIST
+	SDK_READY
+	SDK_NOT_READY
+	SDK_STATE
+	SDK_ERROR
+	RESULT
+	PODCASTS
+	PODCAST_OPEN
+	PODCAST_UPDATE
+	EPISODE_PLAY
+	RADIO
+	DETAIL_OPEN
+	DETAIL
+	PLAY
+	LINK
+	QUEUE
+	QUEUE_LIST
+	LYRICS
+	LYRICS_UPDATE
 )
 
 var eventName = map[event]string{
-	UKNOWN:      "unknown",
-	TOGGLE_PLAY: "togglePlay",
-	NEXT:        "next",
-	PREV:        "prev",
-	SONGCHANGE:  "songChange",
+	UKNOWN:               "unknown",
+	TOGGLE_PLAY:          "togglePlay",
+	NEXT:                 "next",
+	PREV:                 "prev",
+	SONGCHANGE:           "songChange",
+	STATECHANGE:          "stateChange",
+	PLAYLISTS:            "playlists",
+	PLAYLIST_OPEN:        "playlistOpen",
+	PLAYLIST_CREATE:      "playlistCreate",
+	PLAYLIST_RENAME:      "playlistRename",
+	PLAYLIST_ADD_CURRENT: "playlistAddCurrent",
+	PLAYLIST_REMOVE:      "playlistRemove",
+	PLAYLIST_MOVE:        "playlistMove",
+	PLAYLIST_DEDUPE:      "playlistDedupe",
+	PLAYLIST_UPDATE:      "playlistUpdate",
+	LIBRARY_SAVE:         "librarySave",
+	LIBRARY_REMOVE:       "libraryRemove",
+	LIBRARY_STATE:        "libraryState",
+	VOLUME:               "volume",
+	SEEK:                 "seek",
+	DEVICE:               "device",
+	SHUFFLE:              "shuffle",
+	REPEAT:               "repeat",
+	DEVICES:              "devices",
+	DEVICE_LIST:          "deviceList",
+	SDK_READY:            "sdkReady",
+	SDK_NOT_READY:        "sdkNotReady",
+	SDK_STATE:            "sdkState",
+	SDK_ERROR:            "sdkError",
+	RESULT:               "result",
+	PODCASTS:             "podcasts",
+	PODCAST_OPEN:         "podcastOpen",
+	PODCAST_UPDATE:       "podcastUpdate",
+	EPISODE_PLAY:         "episodePlay",
+	RADIO:                "radio",
+	DETAIL_OPEN:          "detailOpen",
+	DETAIL:               "detail",
+	PLAY:                 "play",
+	LINK:                 "link",
+	QUEUE:                "queue",
+	QUEUE_LIST:           "queueList",
+	LYRICS:               "lyrics",
+	LYRICS_UPDATE:        "lyricsUpdate",
 }
 
 func (e event) String() string {
 	return eventName[e]
 }
 
+// Parse returns the kind with the given name, e.g. "togglePlay" for
+// TOGGLE_PLAY.
+func Parse(name string) (Kind, bool) {
+	for e, n := range eventName {
+		if n == name && e != UKNOWN {
+			return e, true
+		}
+	}
+	return UKNOWN, false
+}
+
 type TogglePlay struct {
-	e event
+	e    event
+	data map[any]any
 }
 
 func (u TogglePlay) Data() map[any]any {
-	return nil
+	return u.data
 }
 
 func (u TogglePlay) String() string {
@@ -90,16 +182,586 @@ func (sc SongChange) String() string {
 	return sc.e.String()
 }
 
+type StateChange struct {
+	e    event
+	data map[any]any
+}
+
+func (sc StateChange) Data() map[any]any {
+	return sc.data
+}
+
+func (sc StateChange) String() string {
+	return sc.e.String()
+}
+
+type Playlists struct {
+	e    event
+	data map[any]any
+}
+
+func (p Playlists) Data() map[any]any {
+	return p.data
+}
+
+func (p Playlists) String() string {
+	return p.e.String()
+}
+
+type PlaylistOpen struct {
+	e    event
+	data map[any]any
+}
+
+func (po PlaylistOpen) Data() map[any]any {
+	return po.data
+}
+
+func (po PlaylistOpen) String() string {
+	return po.e.String()
+}
+
+type PlaylistCreate struct {
+	e    event
+	data map[any]any
+}
+
+func (pc PlaylistCreate) Data() map[any]any {
+	return pc.data
+}
+
+func (pc PlaylistCreate) String() string {
+	return pc.e.String()
+}
+
+type PlaylistRename struct {
+	e    event
+	data map[any]any
+}
+
+func (pr PlaylistRename) Data() map[any]any {
+	return pr.data
+}
+
+func (pr PlaylistRename) String() string {
+	return pr.e.String()
+}
+
+type PlaylistAddCurrent struct {
+	e    event
+	data map[any]any
+}
+
+func (pac PlaylistAddCurrent) Data() map[any]any {
+	return pac.data
+}
+
+func (pac PlaylistAddCurrent) String() string {
+	return pac.e.String()
+}
+
+type PlaylistRemove struct {
+	e    event
+	data map[any]any
+}
+
+func (pr PlaylistRemove) Data() map[any]any {
+	return pr.data
+}
+
+func (pr PlaylistRemove) String() string {
+	return pr.e.String()
+}
+
+type PlaylistMove struct {
+	e    event
+	data map[any]any
+}
+
+func (pm PlaylistMove) Data() map[any]any {
+	return pm.data
+}
+
+func (pm PlaylistMove) String() string {
+	return pm.e.String()
+}
+
+type PlaylistDedupe struct {
+	e    event
+	data map[any]any
+}
+
+func (pd PlaylistDedupe) Data() map[any]any {
+	return pd.data
+}
+
+func (pd PlaylistDedupe) String() string {
+	return pd.e.String()
+}
+
+type PlaylistUpdate struct {
+	e    event
+	data map[any]any
+}
+
+func (pu PlaylistUpdate) Data() map[any]any {
+	return pu.data
+}
+
+func (pu PlaylistUpdate) String() string {
+	return pu.e.String()
+}
+
+type LibrarySave struct {
+	e    event
+	data map[any]any
+}
+
+func (ls LibrarySave) Data() map[any]any {
+	return ls.data
+}
+
+func (ls LibrarySave) String() string {
+	return ls.e.String()
+}
+
+type LibraryRemove struct {
+	e    event
+	data map[any]any
+}
+
+func (lr LibraryRemove) Data() map[any]any {
+	return lr.data
+}
+
+func (lr LibraryRemove) String() string {
+	return lr.e.String()
+}
+
+type LibraryState struct {
+	e    event
+	data map[any]any
+}
+
+func (ls LibraryState) Data() map[any]any {
+	return ls.data
+}
+
+func (ls LibraryState) String() string {
+	return ls.e.String()
+}
+
+type Volume struct {
+	e    event
+	data map[any]any
+}
+
+func (v Volume) Data() map[any]any {
+	return v.data
+}
+
+func (v Volume) String() string {
+	return v.e.String()
+}
+
+type Seek struct {
+	e    event
+	data map[any]any
+}
+
+func (s Seek) Data() map[any]any {
+	return s.data
+}
+
+func (s Seek) String() string {
+	return s.e.String()
+}
+
+type Device struct {
+	e    event
+	data map[any]any
+}
+
+func (d Device) Data() map[any]any {
+	return d.data
+}
+
+func (d Device) String() string {
+	return d.e.String()
+}
+
+type Shuffle struct {
+	e    event
+	data map[any]any
+}
+
+func (s Shuffle) Data() map[any]any {
+	return s.data
+}
+
+func (s Shuffle) String() string {
+	return s.e.String()
+}
+
+type Repeat struct {
+	e    event
+	data map[any]any
+}
+
+func (r Repeat) Data() map[any]any {
+	return r.data
+}
+
+func (r Repeat) String() string {
+	return r.e.String()
+}
+
+type Devices struct {
+	e    event
+	data map[any]any
+}
+
+func (d Devices) Data() map[any]any {
+	return d.data
+}
+
+func (d Devices) String() string {
+	return d.e.String()
+}
+
+type DeviceList struct {
+	e    event
+	data map[any]any
+}
+
+func (dl DeviceList) Data() map[any]any {
+	return dl.data
+}
+
+func (dl DeviceList) String() string {
+	return dl.e.String()
+}
+
+type SdkReady struct {
+	e    event
+	data map[any]any
+}
+
+func (sr SdkReady) Data() map[any]any {
+	return sr.data
+}
+
+func (sr SdkReady) String() string {
+	return sr.e.String()
+}
+
+type SdkNotReady struct {
+	e    event
+	data map[any]any
+}
+
+func (snr SdkNotReady) Data() map[any]any {
+	return snr.data
+}
+
+func (snr SdkNotReady) String() string {
+	return snr.e.String()
+}
+
+type SdkState struct {
+	e    event
+	data map[any]any
+}
+
+func (ss SdkState) Data() map[any]any {
+	return ss.data
+}
+
+func (ss SdkState) String() string {
+	return ss.e.String()
+}
+
+type SdkError struct {
+	e    event
+	data map[any]any
+}
+
+func (se SdkError) Data() map[any]any {
+	return se.data
+}
+
+func (se SdkError) String() string {
+	return se.e.String()
+}
+
+type Result struct {
+	e    event
+	data map[any]any
+}
+
+func (r Result) Data() map[any]any {
+	return r.data
+}
+
+func (r Result) String() string {
+	return r.e.String()
+}
+
+type Podcasts struct {
+	e    event
+	data map[any]any
+}
+
+func (p Podcasts) Data() map[any]any {
+	return p.data
+}
+
+func (p Podcasts) String() string {
+	return p.e.String()
+}
+
+type PodcastOpen struct {
+	e    event
+	data map[any]any
+}
+
+func (po PodcastOpen) Data() map[any]any {
+	return po.data
+}
+
+func (po PodcastOpen) String() string {
+	return po.e.String()
+}
+
+type PodcastUpdate struct {
+	e    event
+	data map[any]any
+}
+
+func (pu PodcastUpdate) Data() map[any]any {
+	return pu.data
+}
+
+func (pu PodcastUpdate) String() string {
+	return pu.e.String()
+}
+
+type EpisodePlay struct {
+	e    event
+	data map[any]any
+}
+
+func (ep EpisodePlay) Data() map[any]any {
+	return ep.data
+}
+
+func (ep EpisodePlay) String() string {
+	return ep.e.String()
+}
+
+type Radio struct {
+	e    event
+	data map[any]any
+}
+
+func (r Radio) Data() map[any]any {
+	return r.data
+}
+
+func (r Radio) String() string {
+	return r.e.String()
+}
+
+type DetailOpen struct {
+	e    event
+	data map[any]any
+}
+
+func (do DetailOpen) Data() map[any]any {
+	return do.data
+}
+
+func (do DetailOpen) String() string {
+	return do.e.String()
+}
+
+type Detail struct {
+	e    event
+	data map[any]any
+}
+
+func (d Detail) Data() map[any]any {
+	return d.data
+}
+
+func (d Detail) String() string {
+	return d.e.String()
+}
+
+type Play struct {
+	e    event
+	data map[any]any
+}
+
+func (p Play) Data() map[any]any {
+	return p.data
+}
+
+func (p Play) String() string {
+	return p.e.String()
+}
+
+type Link struct {
+	e    event
+	data map[any]any
+}
+
+func (l Link) Data() map[any]any {
+	return l.data
+}
+
+func (l Link) String() string {
+	return l.e.String()
+}
+
+type Queue struct {
+	e    event
+	data map[any]any
+}
+
+func (q Queue) Data() map[any]any {
+	return q.data
+}
+
+func (q Queue) String() string {
+	return q.e.String()
+}
+
+type QueueList struct {
+	e    event
+	data map[any]any
+}
+
+func (ql QueueList) Data() map[any]any {
+	return ql.data
+}
+
+func (ql QueueList) String() string {
+	return ql.e.String()
+}
+
+type Lyrics struct {
+	e    event
+	data map[any]any
+}
+
+func (l Lyrics) Data() map[any]any {
+	return l.data
+}
+
+func (l Lyrics) String() string {
+	return l.e.String()
+}
+
+type LyricsUpdate struct {
+	e    event
+	data map[any]any
+}
+
+func (lu LyricsUpdate) Data() map[any]any {
+	return lu.data
+}
+
+func (lu LyricsUpdate) String() string {
+	return lu.e.String()
+}
+
 func New(e event, data map[any]any) Event {
 	switch e {
 	case TOGGLE_PLAY:
-		return TogglePlay{TOGGLE_PLAY}
+		return TogglePlay{TOGGLE_PLAY, data}
 	case PREV:
 		return Prev{PREV, data}
 	case NEXT:
 		return Next{NEXT, data}
 	case SONGCHANGE:
 		return SongChange{SONGCHANGE, data}
+	case STATECHANGE:
+		return StateChange{STATECHANGE, data}
+	case PLAYLISTS:
+		return Playlists{PLAYLISTS, data}
+	case PLAYLIST_OPEN:
+		return PlaylistOpen{PLAYLIST_OPEN, data}
+	case PLAYLIST_CREATE:
+		return PlaylistCreate{PLAYLIST_CREATE, data}
+	case PLAYLIST_RENAME:
+		return PlaylistRename{PLAYLIST_RENAME, data}
+	case PLAYLIST_ADD_CURRENT:
+		return PlaylistAddCurrent{PLAYLIST_ADD_CURRENT, data}
+	case PLAYLIST_REMOVE:
+		return PlaylistRemove{PLAYLIST_REMOVE, data}
+	case PLAYLIST_MOVE:
+		return PlaylistMove{PLAYLIST_MOVE, data}
+	case PLAYLIST_DEDUPE:
+		return PlaylistDedupe{PLAYLIST_DEDUPE, data}
+	case PLAYLIST_UPDATE:
+		return PlaylistUpdate{PLAYLIST_UPDATE, data}
+	case LIBRARY_SAVE:
+		return LibrarySave{LIBRARY_SAVE, data}
+	case LIBRARY_REMOVE:
+		return LibraryRemove{LIBRARY_REMOVE, data}
+	case LIBRARY_STATE:
+		return LibraryState{LIBRARY_STATE, data}
+	case VOLUME:
+		return Volume{VOLUME, data}
+	case SEEK:
+		return Seek{SEEK, data}
+	case DEVICE:
+		return Device{DEVICE, data}
+	case SHUFFLE:
+		return Shuffle{SHUFFLE, data}
+	case REPEAT:
+		return Repeat{REPEAT, data}
+	case DEVICES:
+		return Devices{DEVICES, data}
+	case DEVICE_LIST:
+		return DeviceList{DEVICE_LIST, data}
+	case SDK_READY:
+		return SdkReady{SDK_READY, data}
+	case SDK_NOT_READY:
+		return SdkNotReady{SDK_NOT_READY, data}
+	case SDK_STATE:
+		return SdkState{SDK_STATE, data}
+	case SDK_ERROR:
+		return SdkError{SDK_ERROR, data}
+	case RESULT:
+		return Result{RESULT, data}
+	case PODCASTS:
+		return Podcasts{PODCASTS, data}
+	case PODCAST_OPEN:
+		return PodcastOpen{PODCAST_OPEN, data}
+	case PODCAST_UPDATE:
+		return PodcastUpdate{PODCAST_UPDATE, data}
+	case EPISODE_PLAY:
+		return EpisodePlay{EPISODE_PLAY, data}
+	case RADIO:
+		return Radio{RADIO, data}
+	case DETAIL_OPEN:
+		return DetailOpen{DETAIL_OPEN, data}
+	case DETAIL:
+		return Detail{DETAIL, data}
+	case PLAY:
+		return Play{PLAY, data}
+	case LINK:
+		return Link{LINK, data}
+	case QUEUE:
+		return Queue{QUEUE, data}
+	case QUEUE_LIST:
+		return QueueList{QUEUE_LIST, data}
+	case LYRICS:
+		return Lyrics{LYRICS, data}
+	case LYRICS_UPDATE:
+		return LyricsUpdate{LYRICS_UPDATE, data}
 	default:
 		return Unknown{UKNOWN}
 	}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var playsBucket = []byte("plays")
+
+// Play is a single observed playback of a track.
+type Play struct {
+	TrackID     string        `json:"track_id"`
+	TrackName   string        `json:"track_name"`
+	Artists     []string      `json:"artists"`
+	Album       string        `json:"album"`
+	ContextURI  string        `json:"context_uri"`
+	ContextType string        `json:"context_type"`
+	DeviceID    string        `json:"device_id"`
+	DeviceName  string        `json:"device_name"`
+	StartedAt   time.Time     `json:"started_at"`
+	Duration    time.Duration `json:"duration"`
+	Listened    time.Duration `json:"listened"`
+	Skipped     bool          `json:"skipped"`
+}
+
+// Store persists plays in a bolt database, keyed by start time.
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating history dir: %s", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening history db %s: %s", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(playsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating history bucket: %s", err)
+	}
+	return &Store{db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Add(p Play) error {
+	v, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("error encoding play: %s", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(playsBucket)
+		k := key(p.StartedAt)
+		// two plays starting in the same nanosecond are unlikely, but
+		// don't let one overwrite the other
+		for b.Get(k) != nil {
+			binary.BigEndian.PutUint64(k, binary.BigEndian.Uint64(k)+1)
+		}
+		return b.Put(k, v)
+	})
+}
+
+// Range returns all plays started in [from, to), oldest first.
+func (s *Store) Range(from, to time.Time) ([]Play, error) {
+	var plays []Play
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(playsBucket).Cursor()
+		max := key(to)
+		for k, v := c.Seek(key(from)); k != nil && string(k) < string(max); k, v = c.Next() {
+			var p Play
+			if err := json.Unmarshal(v, &p); err != nil {
+				return fmt.Errorf("error decoding play %x: %s", k, err)
+			}
+			plays = append(plays, p)
+		}
+		return nil
+	})
+	return plays, err
+}
+
+func key(t time.Time) []byte {
+	k := make([]byte, 8)
+	n := t.UnixNano()
+	if n < 0 {
+		n = 0
+	}
+	binary.BigEndian.PutUint64(k, uint64(n))
+	return k
+}
@@ -0,0 +1,99 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/zmb3/spotify/v2"
+)
+
+var logger = logging.For("history")
+
+// a track counts as skipped if it was left more than this before its end
+const skipMargin = 10 * time.Second
+
+// polls further apart than this are not counted as listening time,
+// e.g. after the machine was suspended
+const maxGap = 5 * time.Second
+
+// Recorder turns a stream of player states into plays.
+type Recorder struct {
+	store *Store
+
+	mu       sync.Mutex
+	current  *Play
+	progress time.Duration
+	lastSeen time.Time
+}
+
+func NewRecorder(s *Store) *Recorder {
+	return &Recorder{store: s}
+}
+
+// Observe records the given state. A nil state means nothing is playing.
+func (r *Recorder) Observe(ps *spotify.PlayerState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	// only tracks count as plays, episodes end the current one
+	var item *spotify.FullTrack
+	if ps != nil && ps.Item != nil && ps.Item.Type != "episode" {
+		item = ps.Item
+	}
+
+	if r.current != nil && (item == nil || string(item.ID) != r.current.TrackID) {
+		r.finish()
+	}
+	if item == nil {
+		r.lastSeen = now
+		return
+	}
+
+	if r.current == nil {
+		artists := make([]string, 0, len(item.Artists))
+		for _, a := range item.Artists {
+			artists = append(artists, a.Name)
+		}
+		r.current = &Play{
+			TrackID:     string(item.ID),
+			TrackName:   item.Name,
+			Artists:     artists,
+			Album:       item.Album.Name,
+			ContextURI:  string(ps.PlaybackContext.URI),
+			ContextType: ps.PlaybackContext.Type,
+			DeviceID:    string(ps.Device.ID),
+			DeviceName:  ps.Device.Name,
+			StartedAt:   now.Add(-time.Duration(ps.Progress) * time.Millisecond),
+			Duration:    item.TimeDuration(),
+		}
+	} else if ps.Playing {
+		if gap := now.Sub(r.lastSeen); gap < maxGap {
+			r.current.Listened += gap
+		}
+	}
+	r.progress = time.Duration(ps.Progress) * time.Millisecond
+	r.lastSeen = now
+}
+
+// Flush stores the play in progress, if any.
+func (r *Recorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil {
+		r.finish()
+	}
+}
+
+func (r *Recorder) finish() {
+	p := *r.current
+	r.current = nil
+	p.Skipped = r.progress < p.Duration-skipMargin
+	if p.Listened == 0 {
+		return
+	}
+	if err := r.store.Add(p); err != nil {
+		logger.Error("error recording play", "track", p.TrackID, "err", err)
+	}
+}
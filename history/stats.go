@@ -0,0 +1,177 @@
+package history
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Range int
+
+const (
+	TODAY Range = iota
+	WEEK
+	MONTH
+	YEAR
+	ALL
+)
+
+var rangeName = map[Range]string{
+	TODAY: "today",
+	WEEK:  "week",
+	MONTH: "month",
+	YEAR:  "year",
+	ALL:   "all",
+}
+
+func (r Range) String() string {
+	return rangeName[r]
+}
+
+// Since returns the start of the range relative to now.
+func (r Range) Since(now time.Time) time.Time {
+	switch r {
+	case TODAY:
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	case WEEK:
+		return now.AddDate(0, 0, -7)
+	case MONTH:
+		return now.AddDate(0, -1, 0)
+	case YEAR:
+		return now.AddDate(-1, 0, 0)
+	default:
+		return time.Unix(0, 0)
+	}
+}
+
+func ParseRange(s string) (Range, bool) {
+	for r, name := range rangeName {
+		if name == s {
+			return r, true
+		}
+	}
+	return ALL, false
+}
+
+type Count struct {
+	Name     string
+	Plays    int
+	Listened time.Duration
+}
+
+type Stats struct {
+	Plays      int
+	Skips      int
+	Listened   time.Duration
+	TopTracks  []Count
+	TopArtists []Count
+	// Hours is the listened time per hour of the day, in local time.
+	Hours [24]time.Duration
+}
+
+// Compute aggregates plays, keeping the n most listened tracks and artists.
+func Compute(plays []Play, n int) Stats {
+	var st Stats
+	tracks := map[string]*Count{}
+	artists := map[string]*Count{}
+	add := func(m map[string]*Count, k, name string, d time.Duration) {
+		c, ok := m[k]
+		if !ok {
+			c = &Count{Name: name}
+			m[k] = c
+		}
+		c.Plays++
+		c.Listened += d
+	}
+
+	for _, p := range plays {
+		st.Plays++
+		st.Listened += p.Listened
+		if p.Skipped {
+			st.Skips++
+		}
+		st.Hours[p.StartedAt.Local().Hour()] += p.Listened
+
+		name := p.TrackName
+		if len(p.Artists) > 0 {
+			name = strings.Join(p.Artists, ", ") + " - " + name
+		}
+		add(tracks, p.TrackID, name, p.Listened)
+		for _, a := range p.Artists {
+			add(artists, a, a, p.Listened)
+		}
+	}
+
+	st.TopTracks = top(tracks, n)
+	st.TopArtists = top(artists, n)
+	return st
+}
+
+func top(m map[string]*Count, n int) []Count {
+	counts := make([]Count, 0, len(m))
+	for _, c := range m {
+		counts = append(counts, *c)
+	}
+	slices.SortFunc(counts, func(a, b Count) int {
+		if c := cmp.Compare(b.Plays, a.Plays); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Listened, a.Listened); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+var csvHeader = []string{
+	"started_at", "track_id", "track_name", "artists", "album",
+	"context_type", "context_uri", "device_id", "device_name",
+	"duration_ms", "listened_ms", "skipped",
+}
+
+func WriteCSV(w io.Writer, plays []Play) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range plays {
+		err := cw.Write([]string{
+			p.StartedAt.Format(time.RFC3339),
+			p.TrackID,
+			p.TrackName,
+			strings.Join(p.Artists, ";"),
+			p.Album,
+			p.ContextType,
+			p.ContextURI,
+			p.DeviceID,
+			p.DeviceName,
+			strconv.FormatInt(p.Duration.Milliseconds(), 10),
+			strconv.FormatInt(p.Listened.Milliseconds(), 10),
+			strconv.FormatBool(p.Skipped),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteJSON(w io.Writer, plays []Play) error {
+	if plays == nil {
+		plays = []Play{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plays)
+}
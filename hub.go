@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/moritz-tiesler/spoli/event"
+)
+
+// hub fans the events published on the broker's source out to its
+// subscribers, the TUI and web remotes.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan event.Event]bool // true if the subscriber may miss events
+	// the latest state events, new subscribers start with them
+	last map[string]event.Event
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: map[chan event.Event]bool{},
+		last: map[string]event.Event{},
+	}
+}
+
+var replayed = []event.Kind{event.STATECHANGE, event.SONGCHANGE, event.LIBRARY_STATE}
+
+// subscribe returns a channel receiving every published event and a
+// function to cancel the subscription. Lossy subscribers, like slow web
+// clients, miss events when they fall behind instead of blocking
+// everyone else.
+func (h *hub) subscribe(lossy bool) (<-chan event.Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan event.Event, 32)
+	for _, k := range replayed {
+		if e, ok := h.last[k.String()]; ok {
+			ch <- e
+		}
+	}
+	h.subs[ch] = lossy
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *hub) publish(e event.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range replayed {
+		if e.String() == k.String() {
+			h.last[k.String()] = e
+		}
+	}
+	for ch, lossy := range h.subs {
+		if lossy {
+			select {
+			case ch <- e:
+			default:
+			}
+			continue
+		}
+		ch <- e
+	}
+}
+
+// close ends all subscriptions
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
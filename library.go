@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/library"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/zmb3/spotify/v2"
+)
+
+var logLibrary = logging.For("library")
+
+// handleLibraryEvent saves or removes the current item, or the album or
+// show it belongs to, and publishes the new LIBRARY_STATE. An "id" in the
+// data names the item instead, e.g. one that played a moment ago.
+func (c Client) handleLibraryEvent(ctx context.Context, e event.Event, b Broker) error {
+	kind, _ := e.Data()["kind"].(library.Kind)
+	it, err := c.lib.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading current item: %s", err)
+	}
+	if it == nil {
+		return fmt.Errorf("nothing is playing")
+	}
+	id, ok := e.Data()["id"].(spotify.ID)
+	if !ok {
+		if id, err = it.IDOf(kind); err != nil {
+			return err
+		}
+	}
+
+	if kind == "" {
+		kind = it.Kind
+	}
+
+	switch e.(type) {
+	case event.LibrarySave:
+		err = c.lib.Save(ctx, kind, id)
+	case event.LibraryRemove:
+		err = c.lib.Remove(ctx, kind, id)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating library: %s", err)
+	}
+	return c.publishLibraryState(ctx, it, b)
+}
+
+// libraryState reports whether the item and its album or show are saved.
+func (c Client) libraryState(ctx context.Context, it *library.Item) (map[library.Kind]bool, error) {
+	saved := map[library.Kind]bool{}
+	for kind, id := range map[library.Kind]spotify.ID{it.Kind: it.ID, it.Parent: it.ParentID} {
+		if kind == "" || id == "" {
+			continue
+		}
+		s, err := c.lib.Contains(ctx, kind, id)
+		if err != nil {
+			return nil, fmt.Errorf("error reading library: %s", err)
+		}
+		saved[kind] = len(s) > 0 && s[0]
+	}
+	return saved, nil
+}
+
+func (c Client) publishLibraryState(ctx context.Context, it *library.Item, b Broker) error {
+	saved, err := c.libraryState(ctx, it)
+	if err != nil {
+		return err
+	}
+	b.publish(event.New(event.LIBRARY_STATE, map[any]any{
+		"item":  it,
+		"saved": saved,
+	}))
+	return nil
+}
+
+// watchLibrary returns a state observer that publishes the LIBRARY_STATE
+// of every new item.
+func (c Client) watchLibrary(b *Broker) func(*spotify.PlayerState) {
+	var mu sync.Mutex
+	last := ""
+	return func(ps *spotify.PlayerState) {
+		mu.Lock()
+		defer mu.Unlock()
+		// the state is read with episodes, so the item is an episode too.
+		// A state without one, e.g. during an ad, is looked up once.
+		cur := "none"
+		if ps != nil && ps.Item != nil {
+			cur = string(ps.Item.ID)
+		} else if ps != nil {
+			cur = "other"
+		}
+		if cur == last {
+			return
+		}
+		last = cur
+
+		b.goRun(func(ctx context.Context) {
+			it, err := c.lib.Current(ctx)
+			if err != nil || it == nil {
+				if err != nil {
+					logLibrary.Warn("error reading current item", "err", err)
+				}
+				return
+			}
+			if err := c.publishLibraryState(ctx, it, *b); err != nil {
+				logLibrary.Warn("error publishing library state", "err", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const baseURL = "https://api.spotify.com/v1/"
+
+// Kind is a type of item that can be saved to the user's library.
+type Kind string
+
+const (
+	TRACK   Kind = "track"
+	ALBUM   Kind = "album"
+	SHOW    Kind = "show"
+	EPISODE Kind = "episode"
+)
+
+func ParseKind(s string) (Kind, bool) {
+	switch k := Kind(s); k {
+	case TRACK, ALBUM, SHOW, EPISODE:
+		return k, true
+	}
+	return "", false
+}
+
+// Item is the currently playing track or episode together with
+// the album or show it belongs to.
+type Item struct {
+	Kind     Kind
+	ID       spotify.ID
+	Name     string
+	Parent   Kind
+	ParentID spotify.ID
+}
+
+// IDOf returns the id of the item if k is its kind, or of its album or
+// show if k is the parent's kind. An empty kind means the item itself.
+func (it Item) IDOf(k Kind) (spotify.ID, error) {
+	switch k {
+	case "", it.Kind:
+		return it.ID, nil
+	case it.Parent:
+		if it.ParentID != "" {
+			return it.ParentID, nil
+		}
+	}
+	return "", fmt.Errorf("the current %s has no %s", it.Kind, k)
+}
+
+// Library saves and removes items from the user's library. The spotify
+// client only covers tracks and albums, so this talks to the API directly.
+type Library struct {
+	c *http.Client
+}
+
+func New(c *http.Client) Library {
+	return Library{c}
+}
+
+// Current returns the item that is currently playing, including episodes.
+// It returns nil if nothing is playing.
+func (l Library) Current(ctx context.Context) (*Item, error) {
+	var cp struct {
+		Type string `json:"currently_playing_type"`
+		Item *struct {
+			ID    spotify.ID `json:"id"`
+			Name  string     `json:"name"`
+			Album *struct {
+				ID spotify.ID `json:"id"`
+			} `json:"album"`
+			Show *struct {
+				ID spotify.ID `json:"id"`
+			} `json:"show"`
+		} `json:"item"`
+	}
+	err := l.do(ctx, http.MethodGet, "me/player/currently-playing?additional_types=episode", &cp)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Item == nil {
+		return nil, nil
+	}
+	it := &Item{ID: cp.Item.ID, Name: cp.Item.Name}
+	switch {
+	case cp.Type == "episode" && cp.Item.Show != nil:
+		it.Kind, it.Parent, it.ParentID = EPISODE, SHOW, cp.Item.Show.ID
+	case cp.Item.Album != nil:
+		it.Kind, it.Parent, it.ParentID = TRACK, ALBUM, cp.Item.Album.ID
+	default:
+		it.Kind = TRACK
+	}
+	return it, nil
+}
+
+// Contains reports for each id whether it is saved in the library.
+func (l Library) Contains(ctx context.Context, k Kind, ids ...spotify.ID) ([]bool, error) {
+	var saved []bool
+	err := l.do(ctx, http.MethodGet, fmt.Sprintf("me/%ss/contains?ids=%s", k, joinIDs(ids)), &saved)
+	return saved, err
+}
+
+func (l Library) Save(ctx context.Context, k Kind, ids ...spotify.ID) error {
+	return l.do(ctx, http.MethodPut, fmt.Sprintf("me/%ss?ids=%s", k, joinIDs(ids)), nil)
+}
+
+func (l Library) Remove(ctx context.Context, k Kind, ids ...spotify.ID) error {
+	return l.do(ctx, http.MethodDelete, fmt.Sprintf("me/%ss?ids=%s", k, joinIDs(ids)), nil)
+}
+
+// Queue adds a track or episode to the playback queue. The spotify
+// client only queues tracks.
+func (l Library) Queue(ctx context.Context, uri spotify.URI) error {
+	return l.do(ctx, http.MethodPost, "me/player/queue?uri="+url.QueryEscape(string(uri)), nil)
+}
+
+func joinIDs(ids []spotify.ID) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, string(id))
+	}
+	return url.QueryEscape(strings.Join(s, ","))
+}
+
+func (l Library) do(ctx context.Context, method, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := l.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s %s: %s", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error spotify.Error `json:"error"`
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &e) != nil || e.Error.Message == "" {
+			e.Error = spotify.Error{Message: strings.TrimSpace(string(body)), Status: resp.StatusCode}
+		}
+		return e.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil && err != io.EOF {
+		return fmt.Errorf("error decoding %s %s: %s", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package link parses Spotify URIs like spotify:track:ID and links like
+// https://open.spotify.com/intl-de/album/ID?si=abc.
+package link
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Kind is the type of item a link points to
+type Kind string
+
+const (
+	TRACK    Kind = "track"
+	EPISODE  Kind = "episode"
+	ALBUM    Kind = "album"
+	PLAYLIST Kind = "playlist"
+	ARTIST   Kind = "artist"
+	SHOW     Kind = "show"
+)
+
+// Action is what playing a link does
+type Action int
+
+const (
+	// PLAY_CONTEXT plays an album, playlist, artist or show from the start
+	PLAY_CONTEXT Action = iota
+	// PLAY_ITEM plays a single track or episode
+	PLAY_ITEM
+	// ENQUEUE adds a track or episode to the queue
+	ENQUEUE
+)
+
+type Link struct {
+	Kind Kind
+	ID   spotify.ID
+}
+
+func (l Link) URI() spotify.URI {
+	return spotify.URI(fmt.Sprintf("spotify:%s:%s", l.Kind, l.ID))
+}
+
+func (l Link) String() string {
+	return string(l.URI())
+}
+
+// IsContext reports whether the link points to something with tracks or
+// episodes, rather than to a single one
+func (l Link) IsContext() bool {
+	switch l.Kind {
+	case ALBUM, PLAYLIST, ARTIST, SHOW:
+		return true
+	}
+	return false
+}
+
+// Action decides how to play the link. Only tracks and episodes can be
+// queued.
+func (l Link) Action(queue bool) (Action, error) {
+	switch {
+	case queue && l.IsContext():
+		return 0, fmt.Errorf("can't queue %s, only tracks and episodes", l.Kind)
+	case queue:
+		return ENQUEUE, nil
+	case l.IsContext():
+		return PLAY_CONTEXT, nil
+	}
+	return PLAY_ITEM, nil
+}
+
+// Parse reads a Spotify URI or an open.spotify.com link. Surrounding
+// whitespace and angle brackets, as left by some chat apps, are ignored.
+func Parse(s string) (Link, error) {
+	s = strings.Trim(strings.TrimSpace(s), "<>")
+	var parts []string
+	switch {
+	case strings.HasPrefix(s, "spotify:"):
+		parts = strings.Split(strings.TrimPrefix(s, "spotify:"), ":")
+	case strings.Contains(s, "open.spotify.com"):
+		if !strings.Contains(s, "://") {
+			s = "https://" + s
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Host != "open.spotify.com" {
+			return Link{}, fmt.Errorf("not a Spotify link: %q", s)
+		}
+		// the query only carries tracking parameters like si=
+		parts = strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) > 0 && strings.HasPrefix(parts[0], "intl-") {
+			parts = parts[1:]
+		}
+		if len(parts) > 0 && parts[0] == "embed" {
+			parts = parts[1:]
+		}
+	default:
+		return Link{}, fmt.Errorf("not a Spotify URI or link: %q", s)
+	}
+
+	// old playlist links still name the owner, user:NAME:playlist:ID
+	if len(parts) == 4 && parts[0] == "user" {
+		parts = parts[2:]
+	}
+	if len(parts) != 2 {
+		return Link{}, fmt.Errorf("not a Spotify URI or link: %q", s)
+	}
+	l := Link{Kind: Kind(parts[0]), ID: spotify.ID(parts[1])}
+	switch l.Kind {
+	case TRACK, EPISODE, ALBUM, PLAYLIST, ARTIST, SHOW:
+	default:
+		return Link{}, fmt.Errorf("unsupported Spotify link to a %s", l.Kind)
+	}
+	if !validID(string(l.ID)) {
+		return Link{}, fmt.Errorf("invalid Spotify ID %q", l.ID)
+	}
+	return l, nil
+}
+
+// validID reports whether id is base 62, Spotify IDs have 22 characters
+func validID(id string) bool {
+	if len(id) != 22 {
+		return false
+	}
+	for _, r := range id {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
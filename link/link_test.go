@@ -0,0 +1,87 @@
+package link
+
+import "testing"
+
+const id = "4uLU6hMCjMI75M1A2tKUQC"
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want Link
+		err  bool
+	}{
+		{in: "spotify:track:" + id, want: Link{TRACK, id}},
+		{in: "spotify:album:" + id, want: Link{ALBUM, id}},
+		{in: "spotify:playlist:" + id, want: Link{PLAYLIST, id}},
+		{in: "spotify:episode:" + id, want: Link{EPISODE, id}},
+		{in: "spotify:artist:" + id, want: Link{ARTIST, id}},
+		{in: "spotify:show:" + id, want: Link{SHOW, id}},
+		{in: "  <spotify:track:" + id + ">\n", want: Link{TRACK, id}},
+		{in: "https://open.spotify.com/track/" + id, want: Link{TRACK, id}},
+		{in: "https://open.spotify.com/album/" + id + "/", want: Link{ALBUM, id}},
+		{in: "open.spotify.com/playlist/" + id, want: Link{PLAYLIST, id}},
+		// intl-xx paths
+		{in: "https://open.spotify.com/intl-de/track/" + id, want: Link{TRACK, id}},
+		{in: "https://open.spotify.com/intl-pt/episode/" + id, want: Link{EPISODE, id}},
+		// si= and other query parameters
+		{in: "https://open.spotify.com/track/" + id + "?si=a1b2c3d4e5f6", want: Link{TRACK, id}},
+		{in: "https://open.spotify.com/intl-fr/album/" + id + "?si=abc&utm_source=copy-link", want: Link{ALBUM, id}},
+		// embeds
+		{in: "https://open.spotify.com/embed/playlist/" + id, want: Link{PLAYLIST, id}},
+		{in: "https://open.spotify.com/embed/show/" + id + "?utm_source=generator", want: Link{SHOW, id}},
+		// legacy playlist URIs and links naming the owner
+		{in: "spotify:user:someone:playlist:" + id, want: Link{PLAYLIST, id}},
+		{in: "https://open.spotify.com/user/someone/playlist/" + id, want: Link{PLAYLIST, id}},
+		// invalid IDs
+		{in: "spotify:track:4uLU6hMCjMI75M1A2tKUQ", err: true},
+		{in: "spotify:track:4uLU6hMCjMI75M1A2tKUQCX", err: true},
+		{in: "spotify:track:4uLU6hMCjMI75M1A2tKU-C", err: true},
+		{in: "spotify:track:4uLU6hMCjMI75M1A2tKÜQ", err: true},
+		{in: "spotify:track:", err: true},
+		// not Spotify, or nothing to play
+		{in: "", err: true},
+		{in: "https://example.com/track/" + id, err: true},
+		{in: "https://open.spotify.com.evil.com/track/" + id, err: true},
+		{in: "spotify:user:someone", err: true},
+		{in: "spotify:genre:" + id, err: true},
+		{in: "https://open.spotify.com/track", err: true},
+	} {
+		got, err := Parse(c.in)
+		if c.err {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want an error", c.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %s", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestAction(t *testing.T) {
+	for _, c := range []struct {
+		kind  Kind
+		queue bool
+		want  Action
+		err   bool
+	}{
+		{kind: TRACK, want: PLAY_ITEM},
+		{kind: EPISODE, want: PLAY_ITEM},
+		{kind: ALBUM, want: PLAY_CONTEXT},
+		{kind: SHOW, want: PLAY_CONTEXT},
+		{kind: TRACK, queue: true, want: ENQUEUE},
+		{kind: EPISODE, queue: true, want: ENQUEUE},
+		{kind: PLAYLIST, queue: true, err: true},
+		{kind: ARTIST, queue: true, err: true},
+	} {
+		got, err := Link{c.kind, id}.Action(c.queue)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("Action of %s, queue %v = %v, %v", c.kind, c.queue, got, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/zmb3/spotify/v2"
+)
+
+// handleLinkEvent plays or queues the "link" of a LINK event
+func (c Client) handleLinkEvent(ctx context.Context, e event.Event, b Broker) error {
+	d := e.Data()
+	l := d["link"].(link.Link)
+	queue, _ := d["queue"].(bool)
+	return c.playLink(ctx, l, queue)
+}
+
+// playLink plays an album, playlist, artist or show from the start, or a
+// single track or episode. Tracks and episodes can be queued instead.
+func (c Client) playLink(ctx context.Context, l link.Link, queue bool) error {
+	action, err := l.Action(queue)
+	if err != nil {
+		return err
+	}
+	uri := l.URI()
+	switch action {
+	case link.ENQUEUE:
+		if err := c.lib.Queue(ctx, uri); err != nil {
+			return fmt.Errorf("error queueing %s: %s", uri, err)
+		}
+		return nil
+	case link.PLAY_CONTEXT:
+		err = c.PlayOpt(ctx, &spotify.PlayOptions{PlaybackContext: &uri})
+	default:
+		err = c.PlayOpt(ctx, &spotify.PlayOptions{URIs: []spotify.URI{uri}})
+	}
+	if err != nil {
+		return fmt.Errorf("error playing %s: %s", uri, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package logging sets up spoli's structured logs. Records are written
+// to a rotating file and kept in a tail for the TUI's log pane, secrets
+// are redacted from both.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Setup makes the default slog logger, and with it the log package,
+// write records at level or above to the file at path. It returns the
+// tail of recent records and the file to close on exit.
+func Setup(path, level string) (*Tail, *RotatingFile, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, nil, fmt.Errorf("error setting up logging: %s", err)
+	}
+	f, err := OpenRotating(path, defaultMaxSize, defaultBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	tail := NewTail(defaultTailSize)
+	opts := &slog.HandlerOptions{Level: l, ReplaceAttr: redactAttr}
+	slog.SetDefault(slog.New(idHandler{fanout{
+		slog.NewTextHandler(f, opts),
+		slog.NewTextHandler(tail, opts),
+	}}))
+	return tail, f, nil
+}
+
+// For returns the logger of a component, e.g. "broker". It logs to
+// whatever the default logger is at the time, so it may be created
+// before Setup runs.
+func For(component string) *slog.Logger {
+	return slog.New(lazyHandler{attrs: []slog.Attr{slog.String("component", component)}})
+}
+
+type idKey struct{}
+
+// WithID returns a context whose records carry the correlation id, e.g.
+// the ID of the command being handled
+func WithID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// idHandler adds the correlation id of the context to records
+type idHandler struct{ slog.Handler }
+
+func (h idHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(idKey{}).(string); ok {
+		r.AddAttrs(slog.String("id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h idHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return idHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h idHandler) WithGroup(name string) slog.Handler {
+	return idHandler{h.Handler.WithGroup(name)}
+}
+
+// fanout passes records to all of its handlers
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	g := make(fanout, len(f))
+	for i, h := range f {
+		g[i] = h.WithAttrs(attrs)
+	}
+	return g
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	g := make(fanout, len(f))
+	for i, h := range f {
+		g[i] = h.WithGroup(name)
+	}
+	return g
+}
+
+// lazyHandler resolves the default handler when a record is logged
+type lazyHandler struct {
+	attrs []slog.Attr
+	group string
+}
+
+func (h lazyHandler) handler() slog.Handler {
+	d := slog.Default().Handler().WithAttrs(h.attrs)
+	if h.group != "" {
+		d = d.WithGroup(h.group)
+	}
+	return d
+}
+
+func (h lazyHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, l)
+}
+
+func (h lazyHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.group != "" {
+		// attrs after a group belong to it, which needs the real handler
+		return h.handler().WithAttrs(attrs)
+	}
+	return lazyHandler{attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h lazyHandler) WithGroup(name string) slog.Handler {
+	if h.group != "" {
+		return h.handler().WithGroup(name)
+	}
+	return lazyHandler{attrs: h.attrs, group: name}
+}
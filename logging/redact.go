@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// secretKeys are attribute keys whose values are never logged
+var secretKeys = []string{"token", "secret", "password", "authorization", "cookie"}
+
+// secretPatterns find secrets in messages and string values
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)(bearer\s+)[^\s"']+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)((?:access_token|refresh_token|client_secret|code|key|state)=)[^&\s"']+`), "${1}" + redacted},
+	// Spotify's access and refresh tokens
+	{regexp.MustCompile(`\b[AB]Q[A-Za-z0-9_-]{40,}`), redacted},
+	// the session secret
+	{regexp.MustCompile(`\b[0-9a-f]{64}\b`), redacted},
+}
+
+// Redact removes secrets like tokens from s
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+func secretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range secretKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr is the ReplaceAttr of spoli's handlers
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.MessageKey && secretKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch v := a.Value.Any().(type) {
+	case string:
+		return slog.String(a.Key, Redact(v))
+	case error:
+		return slog.String(a.Key, Redact(v.Error()))
+	}
+	return a
+}
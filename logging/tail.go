@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Tail keeps the last lines written to it, for the TUI's log pane
+type Tail struct {
+	mu    sync.Mutex
+	lines []string
+	next  int // where the next line goes once lines is full
+	size  int
+}
+
+func NewTail(size int) *Tail {
+	return &Tail{size: size}
+}
+
+// Write adds the lines in b, the handlers write one record per call
+func (t *Tail) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, line := range bytes.Split(bytes.TrimRight(b, "\n"), []byte("\n")) {
+		if len(t.lines) < t.size {
+			t.lines = append(t.lines, string(line))
+			continue
+		}
+		t.lines[t.next] = string(line)
+		t.next = (t.next + 1) % t.size
+	}
+	return len(b), nil
+}
+
+// Lines returns the kept lines, oldest first
+func (t *Tail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(append([]string(nil), t.lines[t.next:]...), t.lines[:t.next]...)
+}
@@ -0,0 +1,134 @@
+// Package lyrics looks up the lyrics of tracks on LRCLIB, an open lyrics
+// database. The Spotify Web API doesn't offer lyrics. See
+// https://lrclib.net/docs
+package lyrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const baseURL = "https://lrclib.net/api/get"
+
+// ErrNotFound is returned for tracks LRCLIB has no lyrics of
+var ErrNotFound = errors.New("no lyrics found")
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Line is a line of the lyrics, At is zero if the lyrics aren't synced
+type Line struct {
+	At   time.Duration
+	Text string
+}
+
+type Lyrics struct {
+	Lines []Line
+	// Synced lyrics have the time of each line
+	Synced       bool
+	Instrumental bool
+}
+
+// Current returns the index of the line sung at progress, -1 before the
+// first line or if the lyrics aren't synced
+func (l *Lyrics) Current(progress time.Duration) int {
+	if !l.Synced {
+		return -1
+	}
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].At > progress }) - 1
+}
+
+// Track identifies a track, LRCLIB matches all fields
+type Track struct {
+	Artist   string
+	Title    string
+	Album    string
+	Duration time.Duration
+}
+
+// Fetch looks up the lyrics of t, preferring synced ones
+func Fetch(ctx context.Context, t Track) (*Lyrics, error) {
+	q := url.Values{}
+	q.Set("artist_name", t.Artist)
+	q.Set("track_name", t.Title)
+	q.Set("album_name", t.Album)
+	q.Set("duration", strconv.Itoa(int(t.Duration.Round(time.Second).Seconds())))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching lyrics: %s", err)
+	}
+	// LRCLIB asks clients to name themselves
+	req.Header.Set("User-Agent", "spoli (https://github.com/moritz-tiesler/spoli)")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching lyrics: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching lyrics: %s", resp.Status)
+	}
+
+	var body struct {
+		Instrumental bool   `json:"instrumental"`
+		PlainLyrics  string `json:"plainLyrics"`
+		SyncedLyrics string `json:"syncedLyrics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error decoding lyrics: %s", err)
+	}
+	switch {
+	case body.Instrumental:
+		return &Lyrics{Instrumental: true}, nil
+	case body.SyncedLyrics != "":
+		return ParseLRC(body.SyncedLyrics), nil
+	case body.PlainLyrics != "":
+		l := &Lyrics{}
+		for _, text := range strings.Split(body.PlainLyrics, "\n") {
+			l.Lines = append(l.Lines, Line{Text: text})
+		}
+		return l, nil
+	}
+	return nil, ErrNotFound
+}
+
+// timeTag is the time of an LRC line, e.g. [01:02.34]
+var timeTag = regexp.MustCompile(`^\[(\d+):(\d+(?:\.\d+)?)\]`)
+
+// ParseLRC reads synced lyrics in the LRC format. Lines without a time
+// tag, like the [ar:...] header, are left out.
+func ParseLRC(s string) *Lyrics {
+	l := &Lyrics{Synced: true}
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		// a line may have several tags, when it is sung more than once
+		var times []time.Duration
+		for {
+			m := timeTag.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			mins, _ := strconv.Atoi(m[1])
+			secs, _ := strconv.ParseFloat(m[2], 64)
+			times = append(times, time.Duration(mins)*time.Minute+time.Duration(secs*float64(time.Second)))
+			line = line[len(m[0]):]
+		}
+		for _, at := range times {
+			l.Lines = append(l.Lines, Line{At: at, Text: strings.TrimSpace(line)})
+		}
+	}
+	sort.SliceStable(l.Lines, func(i, j int) bool { return l.Lines[i].At < l.Lines[j].At })
+	return l
+}
@@ -2,46 +2,69 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net"
 	"net/http"
 	"os"
-	"path"
+	"os/signal"
 	"slices"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/TheZoraiz/ascii-image-converter/aic_package"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/moritz-tiesler/spoli/config"
+	"github.com/moritz-tiesler/spoli/device"
 	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/history"
+	"github.com/moritz-tiesler/spoli/library"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/moritz-tiesler/spoli/static"
+	"github.com/moritz-tiesler/spoli/theme"
 	"github.com/moritz-tiesler/spoli/tui"
+	"github.com/muesli/termenv"
 	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 	"golang.org/x/oauth2"
 )
 
-const REDIRECT_URL string = "http://127.0.0.1:8080/callback"
-
-var html = `
-<br/>
-<a href="/player/play">Play</a><br/>
-<a href="/player/pause">Pause</a><br/>
-<a href="/player/next">Next track</a><br/>
-<a href="/player/previous">Previous Track</a><br/>
-<a href="/player/shuffle">Shuffle</a><br/>
-
-`
-
 // TODO: use PKCE
 // users will not have to store their client secret
 
 // TODO: use fzf and construct pseudo paths, e.g. songs/..., playlists/..., podcasts/...
-// using chrome headless is a pain in the ass
 var (
-	auth = spotifyauth.New(
-		spotifyauth.WithRedirectURL(REDIRECT_URL),
+	// set up by newAuth once the config is loaded
+	auth *spotifyauth.Authenticator
+	ch   = make(chan struct {
+		c *spotify.Client
+		h *http.Client
+		p *tokenProvider
+	},
+	)
+
+	// state protects the login callback, it is random for every run
+	state string
+
+	logBroker = logging.For("broker")
+	logServer = logging.For("server")
+	logAuth   = logging.For("auth")
+)
+
+func newAuth(cfg *config.Config) *spotifyauth.Authenticator {
+	return spotifyauth.New(
+		spotifyauth.WithRedirectURL(cfg.RedirectURL),
+		spotifyauth.WithClientID(cfg.ClientID),
+		spotifyauth.WithClientSecret(cfg.ClientSecret),
 		// spotifyauth.WithScopes(spotifyauth.ScopeUserReadPrivate),
-		// spotifyauth.WithScopes(spotifyauth.ScopeUserLibraryRead),
 		spotifyauth.WithScopes(
 			spotifyauth.ScopeUserReadCurrentlyPlaying,
 			spotifyauth.ScopeUserReadPlaybackState,
@@ -49,23 +72,16 @@ var (
 			spotifyauth.ScopeUserReadPrivate,
 			spotifyauth.ScopeUserReadEmail,
 			spotifyauth.ScopeStreaming,
+			spotifyauth.ScopePlaylistReadPrivate,
+			spotifyauth.ScopePlaylistReadCollaborative,
+			spotifyauth.ScopePlaylistModifyPublic,
+			spotifyauth.ScopePlaylistModifyPrivate,
+			spotifyauth.ScopeUserLibraryRead,
+			spotifyauth.ScopeUserLibraryModify,
+			scopeReadPlaybackPosition,
 		),
-		// spotifyauth.WithScopes(spotifyauth.ScopePlaylistModifyPublic),
-		// spotifyauth.WithScopes(spotifyauth.ScopePlaylistModifyPrivate),
 	)
-	ch = make(chan struct {
-		c *spotify.Client
-		t *oauth2.Token
-	},
-	)
-
-	// needs closing on exit
-	idChan = make(chan string, 1)
-	tChan  = make(chan string, 1)
-	state  = "abc123"
-
-	LOG_FILE = "/tmp/spoli.logs"
-)
+}
 
 // TODO: on app startup
 // load stored access token, compare expiry date
@@ -76,17 +92,49 @@ type Broker struct {
 	*http.Server
 	outgoing chan event.Event
 	incoming chan event.Event
-	client   *Client
+	// client is set by the login, see spotifyClient
+	client  *atomic.Pointer[Client]
+	watcher *StateWatcher
+	hub     *hub
+	host    *deviceHost
+	bridge  *bridge
+	// the playback devices spoli runs itself, the bridge among them
+	devices []device.PlaybackDevice
+	// pipe runs the commands from the sink
+	pipe *pipeline
+	// history is the listening history, nil if it is disabled
+	history *history.Store
+
+	// ctx is canceled when the broker closes, it stops the goroutines
+	// in wg. The hub keeps running until hubDone is closed.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      *sync.WaitGroup
+	hubDone chan struct{}
+	hubWg   *sync.WaitGroup
+}
+
+// spotifyClient returns the client of the Web API, nil until the user
+// logged in
+func (b Broker) spotifyClient() *Client {
+	return b.client.Load()
 }
 
 func (b Broker) Source() chan event.Event {
 	return b.outgoing
 }
 
+// Subscribe returns a channel receiving the events published on the
+// source, and a function to cancel the subscription.
+func (b Broker) Subscribe() (<-chan event.Event, func()) {
+	return b.hub.subscribe(false)
+}
+
 func (b Broker) Sink() chan event.Event {
 	return b.incoming
 }
 
+// FlushSource drops the events waiting in the source
 func (b Broker) FlushSource() {
 L:
 	for {
@@ -101,6 +149,7 @@ L:
 	}
 }
 
+// FlushSink drops the commands waiting in the sink
 func (b Broker) FlushSink() {
 L:
 	for {
@@ -115,41 +164,183 @@ L:
 	}
 }
 
-func (b *Broker) init() {
+// publish sends e to the source unless the broker is closing
+func (b Broker) publish(e event.Event) {
+	select {
+	case b.outgoing <- e:
+	case <-b.ctx.Done():
+	}
+}
+
+// goRun runs f until the broker closes, f should return once ctx is done
+func (b *Broker) goRun(f func(ctx context.Context)) {
+	b.wg.Add(1)
 	go func() {
-		for e := range b.incoming {
-			log.Println("got event ", e.String())
-			// fmt.Println("got event, client is nil: ", b.client == nil)
+		defer b.wg.Done()
+		f(b.ctx)
+	}()
+}
+
+func (b *Broker) init(ctx context.Context) {
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	b.wg, b.hubWg = &sync.WaitGroup{}, &sync.WaitGroup{}
+	b.hubDone = make(chan struct{})
+	// requests are canceled when the broker closes, so long-lived
+	// streams don't hold up the shutdown
+	b.Server.BaseContext = func(net.Listener) context.Context { return b.ctx }
+	b.pipe = newPipeline(b.ctx, b.goRun, b.handle, b.report)
 
-			err := b.client.handlePlayerEvent(context.Background(), e, *b)
-			if err != nil {
-				log.Printf("error handling %s: %s\n", e.String(), err)
+	b.goRun(func(ctx context.Context) {
+		for {
+			var e event.Event
+			select {
+			case <-ctx.Done():
+				return
+			case e = <-b.incoming:
 			}
-			// log.Println("INCOMING: ", e.String())
+
+			logBroker.Debug("command received", "event", e, "id", commandID(e))
+			var id string
+			if c, ok := e.(event.Command); ok {
+				id, e = c.ID, c.Event
+			}
+			b.pipe.submit(id, e)
 		}
-	}()
+	})
 
+	b.hubWg.Add(1)
 	go func() {
-		// for range b.outgoing {
-		// log.Println("OUTGOING", e.String())
-		// }
+		defer b.hubWg.Done()
+		for {
+			select {
+			case e := <-b.outgoing:
+				b.hub.publish(e)
+			case <-b.hubDone:
+				// deliver what the stopped goroutines published last
+			L:
+				for {
+					select {
+					case e := <-b.outgoing:
+						b.hub.publish(e)
+					default:
+						break L
+					}
+				}
+				b.hub.close()
+				return
+			}
+		}
 	}()
 }
 
+// Close shuts the broker down in order and drains its channels:
+//
+//  1. the broker's context is canceled, which ends open streams and
+//     stops the state watcher, token refresh and the command pipeline.
+//     Running commands finish, commands still waiting in the sink or a
+//     queue are dropped.
+//  2. the server stops accepting requests and waits for running ones
+//  3. spoli's playback devices are closed
+//  4. the events still waiting in the source are delivered and the
+//     subscriptions closed, which ends the TUI's event loop
+//
+// ctx limits how long the server waits for requests to finish.
+func (b *Broker) Close(ctx context.Context) error {
+	b.cancel()
+	err := b.Server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = b.Server.Close()
+	}
+
+	b.wg.Wait()
+	b.FlushSink()
+	b.closeDevices()
+
+	close(b.hubDone)
+	b.hubWg.Wait()
+	return err
+}
+
 func main() {
+	cfg, args, err := config.Load(os.Args[1:])
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(2)
+	}
 
-	f, err := os.OpenFile(LOG_FILE, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	// the CLI logs in too, see login
+	if state, err = randomToken(); err != nil {
+		fmt.Fprintf(os.Stderr, "error creating login state: %s\n", err)
+		os.Exit(1)
+	}
 
+	if ok, err := runCommand(cfg, args); ok {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		os.Exit(2)
+	}
+
+	if err := cfg.ValidateServer(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(2)
+	}
+	auth = newAuth(cfg)
+
+	km, err := tui.LoadKeymap(cfg.KeymapFile)
 	if err != nil {
-		log.Fatalf("could not open log file: %s", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	defer f.Close()
+	statsRange, _ := history.ParseRange(cfg.StatsRange)
+	layout, err := tui.LoadLayout(cfg.LayoutFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	themes, err := theme.Load(cfg.ThemeFile)
+	if err == nil {
+		_, err = themes.Get(cfg.Theme)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// asks the terminal, before the TUI takes it over
+	if err := theme.Setup(cfg.Colors, cfg.Background); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var accent func(string) (lipgloss.AdaptiveColor, error)
+	if cfg.ArtAccent {
+		accent = artAccent(cfg.CacheDir)
+	}
+
+	logTail, logFile, err := logging.Setup(cfg.LogFile, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer logFile.Close()
 
-	log.SetOutput(f)
+	// canceled on SIGINT and SIGTERM, the TUI takes ctrl+c as a key
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	router := http.NewServeMux()
 	s := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Listen,
 		Handler: router,
 	}
 
@@ -157,47 +348,134 @@ func main() {
 		Server:   s,
 		outgoing: make(chan event.Event, 1),
 		incoming: make(chan event.Event, 1),
+		client:   &atomic.Pointer[Client]{},
+		watcher:  NewStateWatcher(cfg.PollInterval),
+		hub:      newHub(),
+	}
+	broker.host = newDeviceHost(broker)
+	broker.bridge = newBridge(broker.host, cfg.CacheDir)
+	broker.devices = append(broker.devices, broker.bridge)
+	if len(cfg.DeviceCommand) > 0 {
+		p, err := device.StartProcess(ctx, broker.host, cfg.DeviceName, cfg.DeviceCommand)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		broker.devices = append(broker.devices, p)
 	}
 
-	setupRoutes(router, broker)
-	broker.init()
+	// the history is optional, e.g. another spoli may hold the db lock
+	var hist tui.History
+	var recorder *history.Recorder
+	store, err := history.Open(cfg.HistoryPath())
+	if err != nil {
+		logBroker.Warn("listening history disabled", "err", err)
+	} else {
+		recorder = history.NewRecorder(store)
+		broker.history = store
+		broker.watcher.Observe(recorder.Observe)
+		hist = store
+	}
+
+	sess, err := newSession(cfg.Origins())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// `spoli status` reads the secret to follow the player state
+	if err := sess.save(cfg.SessionPath()); err != nil {
+		logBroker.Warn("spoli status won't work", "err", err)
+	} else {
+		defer os.Remove(cfg.SessionPath())
+	}
+	broker.init(ctx)
+	setupRoutes(router, broker, cfg, sess)
+	if err := startMPRIS(broker); err != nil {
+		logBroker.Info("MPRIS disabled", "err", err)
+	}
+	if cfg.Notifications {
+		if err := startNotifier(broker, cfg.CacheDir); err != nil {
+			logBroker.Warn("notifications disabled", "err", err)
+		}
+	}
 
 	go func() {
 		err := broker.Server.ListenAndServe()
-		if err != nil {
-			log.Fatalf("error starting server: %s\n", err)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logServer.Error("error starting server", "err", err)
+			fmt.Fprintf(os.Stderr, "error starting server: %s\n", err)
+			os.Exit(1)
 		}
 	}()
 
-	p := tea.NewProgram(tui.InitialModel(broker))
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Alas, there's been an error: %v", err)
+	programOptions := []tea.ProgramOption{tea.WithContext(ctx)}
+	if cfg.Mouse {
+		// reports drags too, for the progress bar
+		programOptions = append(programOptions, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(tui.InitialModel(broker, tui.Options{
+		History:    hist,
+		Keymap:     km,
+		StatsRange: statsRange,
+		Logs:       logTail,
+		Art:        coverArt(cfg.CacheDir),
+		Layout:     layout,
+		LayoutFile: cfg.LayoutFile,
+		Themes:     themes,
+		Theme:      cfg.Theme,
+		Accent:     accent,
+	}), programOptions...)
+	_, runErr := p.Run()
+
+	logBroker.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := broker.Close(shutdownCtx); err != nil {
+		logBroker.Error("error shutting down", "err", err)
+	}
+	// the watcher has stopped, record the play that was going on
+	if recorder != nil {
+		recorder.Flush()
+		store.Close()
+	}
+
+	if runErr != nil && !errors.Is(runErr, tea.ErrProgramKilled) {
+		fmt.Printf("Alas, there's been an error: %v", runErr)
 		os.Exit(1)
 	}
 }
 
+// shutdownTimeout is how long requests may take to finish on exit
+const shutdownTimeout = 5 * time.Second
+
 func completeAuth(w http.ResponseWriter, r *http.Request) {
 	tok, err := auth.Token(r.Context(), state, r)
 	if err != nil {
 		http.Error(w, "Couldn't get token", http.StatusForbidden)
-		log.Fatalf("error getting auth token: %s\n", err)
+		logAuth.Error("error getting auth token", "err", err)
+		return
 	}
 	if st := r.FormValue("state"); st != state {
 		http.NotFound(w, r)
-		log.Fatalf("State mismatch: %s != %s\n", st, state)
+		logAuth.Error("state mismatch in the login callback")
+		return
 	}
 
-	// use the token to get an authenticated client
-	client := spotify.New(auth.Client(r.Context(), tok),
+	// the Go client and the web player share the provider's tokens
+	tokens := newTokenProvider(tok, auth.RefreshToken)
+	httpClient := oauth2.NewClient(context.Background(), tokens)
+	client := spotify.New(httpClient,
 		spotify.WithRetry(true),
 	)
 	// fmt.Fprintf(w, "Login Completed!")
 	ch <- struct {
 		c *spotify.Client
-		t *oauth2.Token
+		h *http.Client
+		p *tokenProvider
 	}{
 		client,
-		tok,
+		httpClient,
+		tokens,
 	}
 }
 
@@ -214,7 +492,8 @@ func listTracks(client *spotify.Client) {
 		}
 		tracks := trackPage.Tracks
 		if err != nil {
-			log.Fatalf("could not get saved tracks: %v", err)
+			fmt.Fprintf(os.Stderr, "could not get saved tracks: %v\n", err)
+			os.Exit(1)
 		}
 		for _, t := range tracks {
 			fmt.Println(t.Name)
@@ -240,17 +519,57 @@ func (c Chain) ThenFunc(h http.HandlerFunc) http.Handler {
 	return c.Then(h)
 }
 
-func toAscii(url string) (string, error) {
+// coverArt returns the cover at url as colored characters of at most
+// width by height cells, for the art pane of the TUI
+func coverArt(cacheDir string) func(url string, width, height int) (string, error) {
+	return func(url string, width, height int) (string, error) {
+		path, err := fetchArt(context.Background(), url, cacheDir)
+		if err != nil {
+			return "", err
+		}
+		// cells are about twice as high as wide
+		height = min(height, width/2)
+		// the converter knows no fewer than 256 colors
+		colored := lipgloss.ColorProfile() == termenv.TrueColor || lipgloss.ColorProfile() == termenv.ANSI256
+		return toAscii(path, height*2, height, colored)
+	}
+}
+
+// artAccent returns the accent color of the cover at url, for the TUI
+func artAccent(cacheDir string) func(url string) (lipgloss.AdaptiveColor, error) {
+	return func(url string) (lipgloss.AdaptiveColor, error) {
+		path, err := fetchArt(context.Background(), url, cacheDir)
+		if err != nil {
+			return lipgloss.AdaptiveColor{}, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return lipgloss.AdaptiveColor{}, fmt.Errorf("error opening cover: %s", err)
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return lipgloss.AdaptiveColor{}, fmt.Errorf("error decoding cover: %s", err)
+		}
+		c, ok := theme.Accent(img)
+		if !ok {
+			return lipgloss.AdaptiveColor{}, fmt.Errorf("the cover has no dominant color")
+		}
+		return c, nil
+	}
+}
+
+func toAscii(path string, width, height int, colored bool) (string, error) {
 
 	flags := aic_package.DefaultFlags()
 
-	flags.Dimensions = []int{50, 25}
-	flags.Colored = true
+	flags.Dimensions = []int{width, height}
+	flags.Colored = colored
 	flags.CustomMap = " .-=+#@"
 	// flags.FontFilePath = "./RobotoMono-Regular.ttf" // If file is in current directory
 	flags.SaveBackgroundColor = [4]int{50, 50, 50, 100}
 
-	asciiArt, err := aic_package.Convert(url, flags)
+	asciiArt, err := aic_package.Convert(path, flags)
 	if err != nil {
 		return "", fmt.Errorf("error converting to ASCII: %s", err)
 	}
@@ -258,7 +577,7 @@ func toAscii(url string) (string, error) {
 
 }
 
-func setupRoutes(router *http.ServeMux, broker *Broker) {
+func setupRoutes(router *http.ServeMux, broker *Broker, cfg *config.Config, sess *session) {
 	// router.Handle("/", http.FileServer(http.Dir("./static")))
 
 	// router.HandleFunc("POST /url", h.PostURL())
@@ -266,55 +585,59 @@ func setupRoutes(router *http.ServeMux, broker *Broker) {
 
 	var client *spotify.Client
 	var playerState *spotify.PlayerState
-	var tok string
 
 	// TODO: pull this out and pass client to router
-	go func() {
+	broker.goRun(func(ctx context.Context) {
 
 		authUrl := auth.AuthURL(state)
 		fmt.Println("Please log in to Spotify by visiting the following page in your browser:", authUrl)
+		fmt.Println("Control spoli from another browser with:", sess.remoteURL(cfg.Origin()))
 
 		// wait for auth to complete
-		c := <-ch
+		var c struct {
+			c *spotify.Client
+			h *http.Client
+			p *tokenProvider
+		}
+		select {
+		case c = <-ch:
+		case <-ctx.Done():
+			return
+		}
 		client = c.c
 
 		// fmt.Println("client is nil: ", client == nil)
-		broker.client = &Client{client}
-
-		log.Printf("Found your %s\n", c.t.RefreshToken)
-		rt, _ := auth.RefreshToken(context.Background(), c.t)
-		log.Printf("Found your refresh %s\n", rt.AccessToken)
+		sc := newClient(client, c.h)
+		broker.client.Store(sc)
+		broker.watcher.Observe(sc.watchLibrary(broker))
+		broker.goRun(func(ctx context.Context) {
+			broker.watcher.Run(ctx, sc, broker)
+		})
 
-		tChan <- rt.AccessToken
+		broker.goRun(c.p.run)
+		broker.host.setTokens(c.p)
 		// use the client to make calls that require authorization
-		user, err := client.CurrentUser(context.Background())
+		user, err := client.CurrentUser(ctx)
 		if err != nil {
-			log.Fatalf("error getting user: %s\n", err)
+			logAuth.Error("error getting user", "err", err)
+			return
 		}
 
-		log.Println("You are logged in as:", user.ID)
+		logAuth.Info("logged in", "user", user.ID)
 
-		playerState, err = client.PlayerState(context.Background())
+		playerState, err = client.PlayerState(ctx)
 		if err != nil {
-			log.Fatalf("error getting player state: %s\n", err)
+			logAuth.Error("error getting player state", "err", err)
+			return
 		}
 
-		go func() {
-			for devId := range idChan {
-				err = client.TransferPlayback(context.Background(), spotify.ID(devId), true)
-				if err != nil {
-					log.Printf("error transferring playback: %s\n", err)
-				}
-				log.Println("Transferred playback to ", devId)
-			}
-		}()
-
-		log.Printf("Found your %s (%s)\n", playerState.Device.Type, playerState.Device.Name)
-	}()
+		logAuth.Info("found device", "type", playerState.Device.Type, "device", playerState.Device.Name)
+	})
 
 	loggingMiddleWare := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Println("Got request for:", r.URL.String())
+			// the query may carry the session key
+			logServer.Debug("request", "method", r.Method, "path", r.URL.Path)
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -325,89 +648,88 @@ func setupRoutes(router *http.ServeMux, broker *Broker) {
 
 	router.Handle("/callback", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
 		completeAuth(w, r)
+		// the player page needs the session to open the socket
+		sess.setCookie(w)
 		w.Header().Add("Content-Type", "")
-		http.Redirect(w, r, "http://127.0.0.1:8080/static/player.html", http.StatusFound)
+		http.Redirect(w, r, cfg.Origin()+"/static/player.html", http.StatusFound)
 	}))
 
-	router.Handle("/", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+	router.Handle("GET /{$}", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/remote", http.StatusFound)
 	}))
 
-	router.Handle("POST /id/{id}", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
-		idString := r.PathValue("id")
-		idChan <- idString
+	// tells the web player where it is served from
+	router.Handle("GET /config.js", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		js, _ := json.Marshal(map[string]string{"origin": cfg.Origin()})
+		w.Header().Set("Content-Type", "text/javascript")
+		fmt.Fprintf(w, "window.spoli = %s;\n", js)
 	}))
 
-	router.Handle("GET /tok", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
-		if tok == "" {
-			tok = <-tChan
-		}
-		log.Println("Sending tok: ", tok)
-		w.Header().Add("Access-Control-Allow-Origin", "http://127.0.0.1:8080")
-		w.Write([]byte(tok))
-	}))
+	// the web player talks to the broker over a single socket
+	router.Handle("GET /ws", broker.bridge.handler(sess))
 
-	router.Handle("POST /art", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
-		urlParam := r.URL.Query().Get("url")
-		log.Println("downloading from ", urlParam)
-		img, err := toAscii(urlParam)
-		if err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+	assets := static.Handler(cfg.StaticDir)
+	router.Handle("/static/", stack.Then(http.StripPrefix("/static", assets)))
+
+	// the web remote, for controlling spoli from e.g. a phone
+	router.Handle("GET /remote", stack.Then(sess.login(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sess.valid(r) {
+			writePage(w, http.StatusUnauthorized, "Not authorized",
+				"Open the remote link printed in the terminal running spoli.", false)
 			return
 		}
-		log.Println("\n", img)
-		w.Write([]byte(img))
-	}))
-
-	pwd, _ := os.Getwd()
-	fDir := path.Join(pwd, "/static")
-	// fmt.Println(fDir)
-
-	fs := http.FileServer(http.Dir(fDir))
-	router.Handle("/static/", stack.Then(http.StripPrefix("/static", fs)))
+		if broker.spotifyClient() == nil {
+			w.Header().Set("Retry-After", "5")
+			writePage(w, http.StatusServiceUnavailable, "Not logged in",
+				"spoli is waiting for you to log in to Spotify. Open the link shown in the terminal running spoli.", true)
+			return
+		}
+		r.URL.Path = "/remote.html"
+		assets.ServeHTTP(w, r)
+	}))))
 
-	router.Handle("/next", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotImplemented)
+	api := append(stack, sess.cors, sess.require)
+	router.Handle("/api/", api.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint"))
 	}))
+	router.Handle("POST /api/command", api.ThenFunc(broker.serveCommand))
+	router.Handle("GET /api/events", api.ThenFunc(broker.serveEvents))
+}
 
-	router.Handle("/toggle", stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotImplemented)
-	}))
+type Client struct {
+	*spotify.Client
+	lib library.Library
+}
 
-	router.HandleFunc("/player/", func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		action := strings.TrimPrefix(r.URL.Path, "/player/")
-		fmt.Println("Got request for:", action)
-		var err error
-		switch action {
-		case "play":
-			err = client.Play(ctx)
-		case "pause":
-			err = client.Pause(ctx)
-		case "next":
-			err = client.Next(ctx)
-		case "previous":
-			err = client.Previous(ctx)
-		case "shuffle":
-			playerState.ShuffleState = !playerState.ShuffleState
-			err = client.Shuffle(ctx, playerState.ShuffleState)
-		}
-		if err != nil {
-			log.Print(err)
-		}
+func newClient(c *spotify.Client, h *http.Client) *Client {
+	return &Client{c, library.New(h)}
+}
 
-		w.Header().Set("Content-Type", "text/html")
-		// fmt.Fprint(w, html)
-	})
+// playerState reads the player state, with episodes as items
+func (c Client) playerState(ctx context.Context) (*spotify.PlayerState, error) {
+	return c.PlayerState(ctx, spotify.AdditionalTypes(spotify.EpisodeAdditionalType))
+}
 
+// songChanged is the SONGCHANGE event for ps. The "item" is nil if
+// nothing is playing.
+func songChanged(ps *spotify.PlayerState) event.Event {
+	var item *spotify.FullTrack
+	if ps != nil {
+		item = ps.Item
+	}
+	return event.New(event.SONGCHANGE, map[any]any{"songName": songOf(ps), "item": item})
 }
 
-type Client struct {
-	*spotify.Client
+// playingType is what is playing, "track" or "episode", empty if nothing
+func playingType(ps *spotify.PlayerState) string {
+	if ps == nil || ps.Item == nil {
+		return ""
+	}
+	return ps.Item.Type
 }
 
 func (c Client) stateChanged(ctx context.Context) (chan *spotify.PlayerState, error) {
-	initial, err := c.PlayerState(ctx)
+	initial, err := c.playerState(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error polling state change: %s", err)
 	}
@@ -415,13 +737,6 @@ func (c Client) stateChanged(ctx context.Context) (chan *spotify.PlayerState, er
 	ch := make(chan *spotify.PlayerState, 1)
 	t := time.After(3 * time.Second)
 
-	diff := func(old, new *spotify.PlayerState) bool {
-		if old.CurrentlyPlaying.Item.Name != new.CurrentlyPlaying.Item.Name {
-			return true
-		}
-		return false
-	}
-
 	go func() {
 		for {
 			select {
@@ -432,8 +747,8 @@ func (c Client) stateChanged(ctx context.Context) (chan *spotify.PlayerState, er
 				close(ch)
 				return
 			default:
-				newState, _ := c.PlayerState(ctx)
-				if diff(initial, newState) {
+				newState, err := c.playerState(ctx)
+				if err == nil && songOf(initial) != songOf(newState) {
 					ch <- newState
 					return
 				} else {
@@ -447,73 +762,109 @@ func (c Client) stateChanged(ctx context.Context) (chan *spotify.PlayerState, er
 }
 
 func (c Client) handlePlayerEvent(ctx context.Context, e event.Event, b Broker) error {
+	switch e.(type) {
+	case event.Playlists, event.PlaylistOpen, event.PlaylistCreate,
+		event.PlaylistRename, event.PlaylistAddCurrent, event.PlaylistRemove,
+		event.PlaylistMove, event.PlaylistDedupe:
+		return c.handlePlaylistEvent(ctx, e, b)
+	case event.LibrarySave, event.LibraryRemove:
+		return c.handleLibraryEvent(ctx, e, b)
+	case event.Device, event.Devices:
+		return c.handleDeviceEvent(ctx, e, b)
+	case event.Podcasts, event.PodcastOpen, event.EpisodePlay:
+		return c.handlePodcastEvent(ctx, e, b)
+	case event.Radio:
+		return c.handleRadioEvent(ctx, e, b)
+	case event.DetailOpen, event.Play:
+		return c.handleDetailEvent(ctx, e, b)
+	case event.Link:
+		return c.handleLinkEvent(ctx, e, b)
+	case event.Queue, event.Lyrics:
+		return c.handlePaneEvent(ctx, e, b)
+	}
+
 	var err error
-	initialPs, err := c.PlayerState(ctx)
+	initialPs, err := c.playerState(ctx)
 
 	if err != nil {
 		return fmt.Errorf("error reading playerstate: %s", err)
 	}
-	log.Printf("Player state: %+v\n", initialPs)
+	logBroker.DebugContext(ctx, "player state", "device", initialPs.Device.Name, "playing", initialPs.Playing)
 	currentDev := initialPs.Device
 	if !currentDev.Active {
 		return fmt.Errorf(
-			"error handling event %s: current player %s is not active player\n",
-			e.String(), initialPs.Device.ID,
+			"error handling event %s: current player %s is not active player: %w",
+			e.String(), initialPs.Device.ID, errNoActiveDevice,
 		)
 	}
 
 	switch e.(type) {
 	case event.TogglePlay:
-		if initialPs.Playing {
+		// "play" and "pause" set the state, it toggles without one
+		play := !initialPs.Playing
+		switch e.Data()["state"] {
+		case "play":
+			play = true
+		case "pause":
+			play = false
+		}
+		switch {
+		case play == initialPs.Playing:
+		case play:
+			err = c.Play(ctx)
+		default:
 			err = c.Pause(ctx)
-			break
 		}
-		err = c.Play(ctx)
 	// case :
 	// 	err = client.Pause(ctx)
 	case event.Next:
-		err = c.Next(ctx)
-		log.Println("called next")
+		for range repeats(e) {
+			if err = c.Next(ctx); err != nil {
+				return fmt.Errorf("error skipping: %s", err)
+			}
+		}
 		sig, err := c.stateChanged(ctx)
-		log.Println("called stateChanged")
 		if err != nil {
-			log.Printf("error reading playerstate: %s\n", err)
+			logBroker.WarnContext(ctx, "error reading player state", "err", err)
 			break
 		}
 		ps := <-sig
 		if ps == nil {
-			log.Println("nil state received")
+			logBroker.DebugContext(ctx, "song didn't change")
 			break
 		}
-		log.Println("new state received")
-		newSong := ps.CurrentlyPlaying.Item.Name
-		log.Println("new song: ", newSong)
-		b.Source() <- event.New(
-			event.SONGCHANGE,
-			map[any]any{"songName": newSong},
-		)
+		newSong := songOf(ps)
+		logBroker.DebugContext(ctx, "song changed", "song", newSong)
+		b.publish(songChanged(ps))
+
+	case event.Volume:
+		err = c.setVolume(ctx, initialPs, e.Data()["volume"].(string))
+	case event.Seek:
+		err = c.seek(ctx, initialPs, e.Data()["position"].(string))
+	case event.Shuffle:
+		err = c.setShuffle(ctx, initialPs, e.Data()["state"].(string))
+	case event.Repeat:
+		err = c.setRepeat(ctx, initialPs, e.Data()["state"].(string))
 
 	case event.Prev:
-		err = c.Previous(ctx)
-		log.Println("called prev")
+		for range repeats(e) {
+			if err = c.Previous(ctx); err != nil {
+				return fmt.Errorf("error skipping back: %s", err)
+			}
+		}
 		sig, err := c.stateChanged(ctx)
-		log.Println("called stateChanged")
 		if err != nil {
-			log.Printf("error reading playerstate: %s\n", err)
+			logBroker.WarnContext(ctx, "error reading player state", "err", err)
 			break
 		}
 		ps := <-sig
 		if ps == nil {
-			log.Println("nil state received")
+			logBroker.DebugContext(ctx, "song didn't change")
 			break
 		}
-		log.Println("new state received")
-		newSong := ps.CurrentlyPlaying.Item.Name
-		log.Println("new song: ", newSong)
-		b.Source() <- event.New(
-			event.SONGCHANGE,
-			map[any]any{"songName": newSong},
-		)
+		newSong := songOf(ps)
+		logBroker.DebugContext(ctx, "song changed", "song", newSong)
+		b.publish(songChanged(ps))
 	}
 	return err
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/mpris"
+	"github.com/zmb3/spotify/v2"
+)
+
+// startMPRIS registers spoli on the D-Bus session bus, so media keys and
+// playerctl control it. DBUS_SESSION_BUS_ADDRESS picks another bus.
+func startMPRIS(b *Broker) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("error connecting to the session bus: %s", err)
+	}
+	p, err := mpris.New(conn, "spoli", func(e event.Event) {
+		select {
+		case b.Sink() <- event.NewCommand("mpris", e):
+		case <-b.ctx.Done():
+		}
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// only the latest state matters, so a stuck session bus may miss
+	// events rather than hold up the broker. This reads until the broker
+	// closes the subscription.
+	events, _ := b.hub.subscribe(true)
+	go func() {
+		defer conn.Close()
+		for e := range events {
+			if e, ok := e.(event.StateChange); ok {
+				ps, _ := e.Data()["state"].(*spotify.PlayerState)
+				p.Update(ps)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,353 @@
+// Package mpris exports spoli as an MPRIS media player on D-Bus, so
+// desktop media keys and tools like playerctl can control it. See
+// https://specifications.freedesktop.org/mpris-spec/latest/
+package mpris
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/zmb3/spotify/v2"
+)
+
+var logger = logging.For("mpris")
+
+const (
+	path        dbus.ObjectPath = "/org/mpris/MediaPlayer2"
+	rootIface                   = "org.mpris.MediaPlayer2"
+	playerIface                 = "org.mpris.MediaPlayer2.Player"
+	noTrack     dbus.ObjectPath = "/org/mpris/MediaPlayer2/TrackList/NoTrack"
+)
+
+// seekTolerance is how far the position may drift from the expected one
+// before clients are told about a seek
+const seekTolerance = 2 * time.Second
+
+// Player is the MPRIS service. Calls from D-Bus are translated to events
+// passed to send, the player state is exported with Update.
+type Player struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+	send  func(event.Event)
+
+	mu       sync.Mutex
+	track    dbus.ObjectPath
+	position time.Duration
+	updated  time.Time
+	playing  bool
+}
+
+// New exports the player on conn and requests the bus name
+// org.mpris.MediaPlayer2.<name>
+func New(conn *dbus.Conn, name string, send func(event.Event)) (*Player, error) {
+	p := &Player{conn: conn, send: send, track: noTrack}
+
+	if err := conn.Export(root{}, path, rootIface); err != nil {
+		return nil, fmt.Errorf("error exporting %s: %s", rootIface, err)
+	}
+	if err := conn.ExportWithMap(player{p}, renamed, path, playerIface); err != nil {
+		return nil, fmt.Errorf("error exporting %s: %s", playerIface, err)
+	}
+	props, err := prop.Export(conn, path, p.properties(name))
+	if err != nil {
+		return nil, fmt.Errorf("error exporting properties: %s", err)
+	}
+	p.props = props
+
+	node := &introspect.Node{
+		Name: string(path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       rootIface,
+				Methods:    introspect.Methods(root{}),
+				Properties: props.Introspection(rootIface),
+			},
+			{
+				Name:       playerIface,
+				Methods:    playerMethods(),
+				Properties: props.Introspection(playerIface),
+				Signals: []introspect.Signal{{
+					Name: "Seeked",
+					Args: []introspect.Arg{{Name: "Position", Type: "x"}},
+				}},
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, fmt.Errorf("error exporting introspection: %s", err)
+	}
+
+	reply, err := conn.RequestName(rootIface+"."+name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting bus name: %s", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("bus name %s.%s is taken, is another spoli running?", rootIface, name)
+	}
+	return p, nil
+}
+
+func (p *Player) properties(name string) prop.Map {
+	return prop.Map{
+		rootIface: {
+			"CanQuit":             {Value: false, Emit: prop.EmitConst},
+			"CanRaise":            {Value: false, Emit: prop.EmitConst},
+			"HasTrackList":        {Value: false, Emit: prop.EmitConst},
+			"Identity":            {Value: name, Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{"spotify", "https"}, Emit: prop.EmitConst},
+			"SupportedMimeTypes":  {Value: []string{}, Emit: prop.EmitConst},
+		},
+		playerIface: {
+			"PlaybackStatus": {Value: "Stopped", Emit: prop.EmitTrue},
+			"LoopStatus":     {Value: "None", Writable: true, Emit: prop.EmitTrue, Callback: p.setLoopStatus},
+			"Rate":           {Value: 1.0, Emit: prop.EmitConst},
+			"MinimumRate":    {Value: 1.0, Emit: prop.EmitConst},
+			"MaximumRate":    {Value: 1.0, Emit: prop.EmitConst},
+			"Shuffle":        {Value: false, Writable: true, Emit: prop.EmitTrue, Callback: p.setShuffle},
+			"Metadata":       {Value: metadata(nil), Emit: prop.EmitTrue},
+			"Volume":         {Value: 0.0, Writable: true, Emit: prop.EmitTrue, Callback: p.setVolume},
+			// clients read the position when they need it, changes aren't
+			// signaled, see Seeked
+			"Position":      {Value: int64(0), Emit: prop.EmitFalse},
+			"CanGoNext":     {Value: true, Emit: prop.EmitConst},
+			"CanGoPrevious": {Value: true, Emit: prop.EmitConst},
+			"CanPlay":       {Value: true, Emit: prop.EmitConst},
+			"CanPause":      {Value: true, Emit: prop.EmitConst},
+			"CanSeek":       {Value: true, Emit: prop.EmitConst},
+			"CanControl":    {Value: true, Emit: prop.EmitConst},
+		},
+	}
+}
+
+// Update exports the player state, ps is nil if nothing is playing.
+// Clients are sent PropertiesChanged for the values that changed.
+func (p *Player) Update(ps *spotify.PlayerState) {
+	status := "Stopped"
+	var position time.Duration
+	if ps != nil && ps.Item != nil {
+		status = "Paused"
+		if ps.Playing {
+			status = "Playing"
+		}
+		position = time.Duration(ps.Progress) * time.Millisecond
+	}
+	meta := metadata(ps)
+
+	p.mu.Lock()
+	track := meta["mpris:trackid"].Value().(dbus.ObjectPath)
+	// a jump within the same track is a seek, the expected position
+	// moves on while playing
+	expected := p.position
+	if p.playing {
+		expected += time.Since(p.updated)
+	}
+	seeked := track == p.track && track != noTrack && (position-expected).Abs() > seekTolerance
+	p.track, p.position, p.updated = track, position, time.Now()
+	p.playing = status == "Playing"
+	p.mu.Unlock()
+
+	p.set(playerIface, "Metadata", meta)
+	p.set(playerIface, "PlaybackStatus", status)
+	p.set(playerIface, "Position", position.Microseconds())
+	if ps != nil {
+		p.set(playerIface, "Shuffle", ps.ShuffleState)
+		p.set(playerIface, "LoopStatus", loopStatus(ps.RepeatState))
+		p.set(playerIface, "Volume", float64(ps.Device.Volume)/100)
+	}
+	if seeked {
+		if err := p.conn.Emit(path, playerIface+".Seeked", position.Microseconds()); err != nil {
+			logger.Warn("error emitting Seeked", "err", err)
+		}
+	}
+}
+
+// set changes a property if its value differs, which signals the change.
+// prop's Set is the D-Bus method, it refuses read-only properties and
+// runs the callbacks, so SetMust is used and its panic is logged.
+func (p *Player) set(iface, name string, v any) {
+	if reflect.DeepEqual(p.props.GetMust(iface, name), v) {
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn("error setting property", "property", name, "err", err)
+		}
+	}()
+	p.props.SetMust(iface, name, v)
+}
+
+func metadata(ps *spotify.PlayerState) map[string]dbus.Variant {
+	if ps == nil || ps.Item == nil {
+		return map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(noTrack)}
+	}
+	t := ps.Item
+	artists := make([]string, 0, len(t.Artists))
+	for _, a := range t.Artists {
+		artists = append(artists, a.Name)
+	}
+	kind := link.Kind(t.Type)
+	if kind == "" {
+		kind = link.TRACK
+	}
+	m := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(trackPath(kind, t.ID)),
+		"mpris:length":  dbus.MakeVariant(t.TimeDuration().Microseconds()),
+		"xesam:title":   dbus.MakeVariant(t.Name),
+		"xesam:album":   dbus.MakeVariant(t.Album.Name),
+		"xesam:artist":  dbus.MakeVariant(artists),
+		"xesam:url":     dbus.MakeVariant(fmt.Sprintf("https://open.spotify.com/%s/%s", kind, t.ID)),
+	}
+	if len(t.Album.Images) > 0 {
+		m["mpris:artUrl"] = dbus.MakeVariant(t.Album.Images[0].URL)
+	}
+	return m
+}
+
+func loopStatus(repeat string) string {
+	switch repeat {
+	case "track":
+		return "Track"
+	case "context":
+		return "Playlist"
+	}
+	return "None"
+}
+
+func (p *Player) setLoopStatus(c *prop.Change) *dbus.Error {
+	state, ok := map[string]string{"None": "off", "Track": "track", "Playlist": "context"}[c.Value.(string)]
+	if !ok {
+		return prop.ErrInvalidArg
+	}
+	p.send(event.New(event.REPEAT, map[any]any{"state": state}))
+	return nil
+}
+
+func (p *Player) setShuffle(c *prop.Change) *dbus.Error {
+	state := "off"
+	if c.Value.(bool) {
+		state = "on"
+	}
+	p.send(event.New(event.SHUFFLE, map[any]any{"state": state}))
+	return nil
+}
+
+func (p *Player) setVolume(c *prop.Change) *dbus.Error {
+	v := min(max(c.Value.(float64), 0), 1)
+	p.send(event.New(event.VOLUME, map[any]any{"volume": fmt.Sprint(int(v*100 + 0.5))}))
+	return nil
+}
+
+// root implements org.mpris.MediaPlayer2. spoli runs in a terminal, it
+// can't be raised and quits with the TUI.
+type root struct{}
+
+func (root) Raise() *dbus.Error {
+	return nil
+}
+
+func (root) Quit() *dbus.Error {
+	return nil
+}
+
+// renamed maps Go methods to D-Bus methods whose names Go reserves for
+// other signatures, like Seek
+var renamed = map[string]string{"SeekBy": "Seek"}
+
+func playerMethods() []introspect.Method {
+	ms := introspect.Methods(player{})
+	for i, m := range ms {
+		if name, ok := renamed[m.Name]; ok {
+			ms[i].Name = name
+		}
+	}
+	return ms
+}
+
+// player implements the methods of org.mpris.MediaPlayer2.Player
+type player struct {
+	p *Player
+}
+
+func (pl player) toggle(state string) *dbus.Error {
+	pl.p.send(event.New(event.TOGGLE_PLAY, map[any]any{"state": state}))
+	return nil
+}
+
+func (pl player) Play() *dbus.Error {
+	return pl.toggle("play")
+}
+
+func (pl player) Pause() *dbus.Error {
+	return pl.toggle("pause")
+}
+
+func (pl player) Stop() *dbus.Error {
+	return pl.toggle("pause")
+}
+
+func (pl player) PlayPause() *dbus.Error {
+	return pl.toggle("")
+}
+
+func (pl player) Next() *dbus.Error {
+	pl.p.send(event.New(event.NEXT, nil))
+	return nil
+}
+
+func (pl player) Previous() *dbus.Error {
+	pl.p.send(event.New(event.PREV, nil))
+	return nil
+}
+
+// SeekBy is Seek, moving by offset microseconds. SEEK takes whole seconds.
+func (pl player) SeekBy(offset int64) *dbus.Error {
+	secs := time.Duration(offset*int64(time.Microsecond)).Round(time.Second) / time.Second
+	if secs != 0 {
+		pl.p.send(event.New(event.SEEK, map[any]any{"position": fmt.Sprintf("%+d", secs)}))
+	}
+	return nil
+}
+
+// SetPosition seeks to position microseconds, if track is still playing
+func (pl player) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	pl.p.mu.Lock()
+	current := pl.p.track
+	pl.p.mu.Unlock()
+	if track != current || position < 0 {
+		return nil
+	}
+	secs := time.Duration(position*int64(time.Microsecond)).Round(time.Second) / time.Second
+	pl.p.send(event.New(event.SEEK, map[any]any{"position": fmt.Sprint(int(secs))}))
+	return nil
+}
+
+// OpenUri plays a Spotify URI or link
+func (pl player) OpenUri(uri string) *dbus.Error {
+	l, err := link.Parse(uri)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	pl.p.send(event.New(event.LINK, map[any]any{"link": l}))
+	return nil
+}
+
+// trackPath is the object path clients pass back to SetPosition. Items
+// without an ID, like local files, get noTrack.
+func trackPath(kind link.Kind, id spotify.ID) dbus.ObjectPath {
+	p := dbus.ObjectPath(strings.Join([]string{string(path), string(kind), string(id)}, "/"))
+	if id == "" || !p.IsValid() {
+		return noTrack
+	}
+	return p
+}
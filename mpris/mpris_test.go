@@ -0,0 +1,177 @@
+package mpris
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/zmb3/spotify/v2"
+)
+
+// privateBus starts a session bus of its own and returns its address
+func privateBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("error starting dbus-daemon: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Skipf("dbus-daemon printed no address: %s", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("error connecting to the bus: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// start exports a player on a private bus and returns it, the events it
+// sends and the player object as a client sees it
+func start(t *testing.T) (*Player, <-chan event.Event, *dbus.Conn, dbus.BusObject) {
+	addr := privateBus(t)
+	sent := make(chan event.Event, 10)
+	p, err := New(connect(t, addr), "test", func(e event.Event) { sent <- e })
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := connect(t, addr)
+	return p, sent, client, client.Object(rootIface+".test", path)
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	var zero T
+	return zero
+}
+
+func TestMethods(t *testing.T) {
+	_, sent, _, obj := start(t)
+	for _, c := range []struct {
+		method string
+		args   []any
+		want   string
+		data   map[any]any
+	}{
+		{"Play", nil, "togglePlay", map[any]any{"state": "play"}},
+		{"Next", nil, "next", nil},
+		{"Seek", []any{int64(5 * time.Second / time.Microsecond)}, "seek", map[any]any{"position": "+5"}},
+		{"Seek", []any{int64(-10 * time.Second / time.Microsecond)}, "seek", map[any]any{"position": "-10"}},
+	} {
+		if err := obj.Call(playerIface+"."+c.method, 0, c.args...).Err; err != nil {
+			t.Fatalf("%s: %s", c.method, err)
+		}
+		e := receive(t, sent)
+		if e.String() != c.want {
+			t.Errorf("%s sent %s, want %s", c.method, e, c.want)
+		}
+		for k, v := range c.data {
+			if e.Data()[k] != v {
+				t.Errorf("%s sent %s = %v, want %v", c.method, k, e.Data()[k], v)
+			}
+		}
+	}
+}
+
+func state(progress int, playing bool) *spotify.PlayerState {
+	ps := &spotify.PlayerState{}
+	ps.Playing = playing
+	ps.Progress = spotify.Numeric(progress)
+	ps.Item = &spotify.FullTrack{}
+	ps.Item.ID = "abc"
+	ps.Item.Name = "Song"
+	ps.Item.Type = "track"
+	ps.Item.Duration = 200000
+	return ps
+}
+
+func TestUpdate(t *testing.T) {
+	p, _, client, obj := start(t)
+	if err := client.AddMatchSignal(dbus.WithMatchObjectPath(path)); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	client.Signal(signals)
+
+	p.Update(state(10000, true))
+	// every property is signaled on its own
+	changed := map[string]dbus.Variant{}
+	for changed["PlaybackStatus"] == (dbus.Variant{}) || changed["Metadata"] == (dbus.Variant{}) {
+		s := receive(t, signals)
+		if s.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" {
+			t.Fatalf("got signal %s, want PropertiesChanged", s.Name)
+		}
+		for k, v := range s.Body[1].(map[string]dbus.Variant) {
+			changed[k] = v
+		}
+	}
+	if status := changed["PlaybackStatus"].Value(); status != "Playing" {
+		t.Errorf("PlaybackStatus = %v, want Playing", status)
+	}
+	title := changed["Metadata"].Value().(map[string]dbus.Variant)["xesam:title"].Value()
+	if title != "Song" {
+		t.Errorf("title = %v, want Song", title)
+	}
+	pos, err := obj.GetProperty(playerIface + ".Position")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Value() != int64(10*time.Second/time.Microsecond) {
+		t.Errorf("Position = %v, want 10s", pos.Value())
+	}
+
+	// a jump within the track is a seek
+	p.Update(state(100000, true))
+	for {
+		s := receive(t, signals)
+		if s.Name == playerIface+".Seeked" {
+			if s.Body[0] != int64(100*time.Second/time.Microsecond) {
+				t.Errorf("Seeked to %v, want 100s", s.Body[0])
+			}
+			break
+		}
+	}
+}
+
+func TestUpdateLocalFile(t *testing.T) {
+	p, _, _, obj := start(t)
+	// local files have no Spotify ID
+	ps := state(10000, true)
+	ps.Item.ID = ""
+	p.Update(ps)
+	meta, err := obj.GetProperty(playerIface + ".Metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := meta.Value().(map[string]dbus.Variant)["mpris:trackid"].Value()
+	if id != noTrack {
+		t.Errorf("trackid = %v, want %v", id, noTrack)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/library"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/moritz-tiesler/spoli/notify"
+	"github.com/zmb3/spotify/v2"
+)
+
+var logNotifier = logging.For("notifier")
+
+// notifyInterval is the least time between two notifications
+const notifyInterval = 3 * time.Second
+
+// startNotifier shows a desktop notification with the cover for every new
+// track, with buttons to skip or like it
+func startNotifier(b *Broker, cacheDir string) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("error connecting to the session bus: %s", err)
+	}
+	send := func(e event.Event) {
+		select {
+		case b.Sink() <- event.NewCommand("notify", e):
+		case <-b.ctx.Done():
+		}
+	}
+	n, err := notify.New(conn, "spoli", notifyInterval, func(key string) {
+		action, id, _ := strings.Cut(key, ":")
+		switch action {
+		case "next":
+			send(event.New(event.NEXT, nil))
+		case "like":
+			send(event.New(event.LIBRARY_SAVE, map[any]any{"kind": library.TRACK, "id": spotify.ID(id)}))
+		}
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// fetching the cover and talking to the notification service may be
+	// slow, so the notes are shown by a goroutine of their own. It only
+	// gets the newest track, the subscription may miss events rather than
+	// hold up the broker. This reads until the broker closes it.
+	events, _ := b.hub.subscribe(true)
+	tracks := make(chan *spotify.FullTrack, 1)
+	go func() {
+		defer close(tracks)
+		var last spotify.ID
+		for e := range events {
+			if _, ok := e.(event.SongChange); !ok {
+				continue
+			}
+			// skipping and the watcher both report the same change
+			t, _ := e.Data()["item"].(*spotify.FullTrack)
+			if t == nil || t.ID == last {
+				continue
+			}
+			last = t.ID
+			select {
+			case <-tracks:
+			default:
+			}
+			tracks <- t
+		}
+	}()
+	go func() {
+		defer conn.Close()
+		defer n.Close()
+		for t := range tracks {
+			n.Show(noteOf(b, t, cacheDir))
+		}
+	}()
+	return nil
+}
+
+func noteOf(b *Broker, t *spotify.FullTrack, cacheDir string) notify.Note {
+	names := make([]string, 0, len(t.Artists))
+	for _, a := range t.Artists {
+		names = append(names, a.Name)
+	}
+	note := notify.Note{
+		Title:   t.Name,
+		Body:    strings.Join(names, ", "),
+		Actions: []notify.Action{{Key: "next", Label: "Skip"}},
+	}
+	if t.Album.Name != "" {
+		note.Body += "\n" + t.Album.Name
+	}
+	if t.Type != "episode" {
+		note.Actions = append(note.Actions, notify.Action{Key: "like:" + string(t.ID), Label: "Like"})
+	}
+	// the smallest cover is enough for a thumbnail
+	if imgs := t.Album.Images; len(imgs) > 0 {
+		path, err := fetchArt(b.ctx, imgs[len(imgs)-1].URL, cacheDir)
+		if err != nil {
+			logNotifier.Debug("no cover for notification", "err", err)
+		}
+		note.Image = path
+	}
+	return note
+}
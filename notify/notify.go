@@ -0,0 +1,177 @@
+// Package notify sends desktop notifications through the freedesktop
+// notification service on D-Bus. See
+// https://specifications.freedesktop.org/notification-spec/latest/
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/moritz-tiesler/spoli/logging"
+)
+
+var logger = logging.For("notify")
+
+const (
+	service                 = "org.freedesktop.Notifications"
+	path    dbus.ObjectPath = "/org/freedesktop/Notifications"
+)
+
+// timeout is how long a notification is shown, in ms
+const timeout = 5000
+
+// callTimeout is how long the notification service may take to answer
+const callTimeout = 5 * time.Second
+
+// Action is a button on a notification
+type Action struct {
+	Key   string // passed to the action handler
+	Label string
+}
+
+type Note struct {
+	Title string
+	Body  string
+	// Image is the path of a picture to show, may be empty
+	Image   string
+	Actions []Action
+}
+
+// Notifier shows one notification at a time, each replaces the one
+// before. Notes arriving faster than the interval are held back and only
+// the newest is shown when the interval is up, so skipping through
+// tracks doesn't flood the desktop.
+type Notifier struct {
+	conn     *dbus.Conn
+	app      string
+	interval time.Duration
+	onAction func(key string)
+
+	mu      sync.Mutex
+	id      uint32 // of the last notification, 0 before the first
+	last    time.Time
+	pending *Note
+	timer   *time.Timer
+}
+
+// New returns a notifier for the app. onAction is called with the key of
+// an action the user clicked.
+func New(conn *dbus.Conn, app string, interval time.Duration, onAction func(key string)) (*Notifier, error) {
+	n := &Notifier{conn: conn, app: app, interval: interval, onAction: onAction}
+	var caps []string
+	if err := conn.Object(service, path).Call(service+".GetCapabilities", 0).Store(&caps); err != nil {
+		return nil, fmt.Errorf("error reaching the notification service: %s", err)
+	}
+
+	err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(service),
+		dbus.WithMatchMember("ActionInvoked"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error subscribing to notification actions: %s", err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	go n.listen(signals)
+	return n, nil
+}
+
+// listen calls the action handler for clicks on the own notifications,
+// until the connection closes
+func (n *Notifier) listen(signals chan *dbus.Signal) {
+	for s := range signals {
+		if s.Name != service+".ActionInvoked" || len(s.Body) != 2 {
+			continue
+		}
+		id, _ := s.Body[0].(uint32)
+		key, _ := s.Body[1].(string)
+		n.mu.Lock()
+		own := id != 0 && id == n.id
+		n.mu.Unlock()
+		if own && n.onAction != nil {
+			n.onAction(key)
+		}
+	}
+}
+
+// Show shows note now, or when the interval since the last one is up
+func (n *Notifier) Show(note Note) {
+	n.mu.Lock()
+	wait := n.interval - time.Since(n.last)
+	if wait <= 0 {
+		n.last = time.Now()
+		n.mu.Unlock()
+		n.show(note)
+		return
+	}
+	n.pending = &note
+	if n.timer == nil {
+		n.timer = time.AfterFunc(wait, n.flush)
+	}
+	n.mu.Unlock()
+}
+
+func (n *Notifier) flush() {
+	n.mu.Lock()
+	n.timer = nil
+	note := n.pending
+	n.pending = nil
+	if note != nil {
+		n.last = time.Now()
+	}
+	n.mu.Unlock()
+	if note != nil {
+		n.show(*note)
+	}
+}
+
+// show sends note. The call is made without n.mu, so a slow service
+// doesn't hold up the actions.
+func (n *Notifier) show(note Note) {
+	var actions []string
+	for _, a := range note.Actions {
+		actions = append(actions, a.Key, a.Label)
+	}
+	hints := map[string]dbus.Variant{
+		"category": dbus.MakeVariant("x-gnome.music"),
+		// don't pile up in the notification history
+		"transient": dbus.MakeVariant(true),
+	}
+	icon := ""
+	if note.Image != "" {
+		icon = (&url.URL{Scheme: "file", Path: note.Image}).String()
+		hints["image-path"] = dbus.MakeVariant(icon)
+	}
+	n.mu.Lock()
+	replaces := n.id
+	n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	var id uint32
+	call := n.conn.Object(service, path).CallWithContext(ctx, service+".Notify", 0,
+		n.app, replaces, icon, note.Title, note.Body, actions, hints, int32(timeout))
+	if err := call.Store(&id); err != nil {
+		logger.Warn("error sending notification", "err", err)
+		return
+	}
+	n.mu.Lock()
+	n.id = id
+	n.mu.Unlock()
+}
+
+// Close stops a held back note from being shown
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	n.pending = nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/lyrics"
+	"github.com/zmb3/spotify/v2"
+)
+
+// handlePaneEvent loads what the queue and lyrics panes of the TUI show,
+// published as QUEUE_LIST and LYRICS_UPDATE events
+func (c Client) handlePaneEvent(ctx context.Context, e event.Event, b Broker) error {
+	switch e.(type) {
+	case event.Queue:
+		update := map[any]any{}
+		q, err := c.GetQueue(ctx)
+		if err != nil {
+			err = fmt.Errorf("error reading queue: %s", err)
+			update["error"] = err
+		} else {
+			update["id"] = q.CurrentlyPlaying.ID
+			items := make([]string, 0, len(q.Items))
+			for _, t := range q.Items {
+				items = append(items, trackTitle(t))
+			}
+			update["items"] = items
+		}
+		// a newer read publishes instead
+		if superseded(ctx) {
+			return ctx.Err()
+		}
+		b.publish(event.New(event.QUEUE_LIST, update))
+		return err
+
+	case event.Lyrics:
+		ps, err := c.playerState(ctx)
+		if err != nil {
+			return fmt.Errorf("error reading player state: %s", err)
+		}
+		if ps.Item == nil {
+			return fmt.Errorf("nothing is playing")
+		}
+		t := ps.Item
+		update := map[any]any{"id": t.ID}
+		// LRCLIB lists tracks under their main artist
+		artist := ""
+		if len(t.Artists) > 0 {
+			artist = t.Artists[0].Name
+		}
+		l, err := lyrics.Fetch(ctx, lyrics.Track{
+			Artist:   artist,
+			Title:    t.Name,
+			Album:    t.Album.Name,
+			Duration: t.TimeDuration(),
+		})
+		if err != nil {
+			update["error"] = err
+		} else {
+			update["lyrics"] = l
+		}
+		// a newer read publishes instead
+		if superseded(ctx) {
+			return ctx.Err()
+		}
+		b.publish(event.New(event.LYRICS_UPDATE, update))
+		// tracks without lyrics are no failure of the command
+		if errors.Is(err, lyrics.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func trackTitle(t spotify.FullTrack) string {
+	if len(t.Artists) == 0 {
+		return t.Name
+	}
+	return artistsOf(t.Artists) + " - " + t.Name
+}
+
+func artistsOf(as []spotify.SimpleArtist) string {
+	names := make([]string, 0, len(as))
+	for _, a := range as {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
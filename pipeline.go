@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/moritz-tiesler/spoli/playlist"
+	"github.com/zmb3/spotify/v2"
+)
+
+// pipeline runs the commands from the sink. Reads run concurrently and a
+// newer read cancels an older one of the same kind. Writes are queued
+// per target and run in order, so a skip lands before the save that
+// follows it. Repeated keypresses still waiting in a queue are merged
+// into one command, e.g. five "next" into one skip by five.
+type pipeline struct {
+	ctx   context.Context
+	goRun func(f func(ctx context.Context))
+	// run runs a command, result reports its outcome
+	run    func(ctx context.Context, e event.Event) error
+	result func(j job, err error)
+
+	mu sync.Mutex
+	// the writes waiting per target, a target is present while its
+	// worker runs
+	queues map[string][]job
+	reads  map[string]*readJob
+}
+
+// job is a command, merged from one or more commands from the sink
+type job struct {
+	e   event.Event
+	ids []string
+}
+
+type readJob struct {
+	cancel context.CancelCauseFunc
+	ids    []string
+}
+
+// errSuperseded cancels a read that a newer one of the same kind replaced
+var errSuperseded = errors.New("superseded by a newer request")
+
+// superseded reports whether a newer read replaced the one running in
+// ctx. Its answer would be outdated, the handlers don't publish it.
+func superseded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errSuperseded)
+}
+
+func newPipeline(
+	ctx context.Context,
+	goRun func(f func(ctx context.Context)),
+	run func(ctx context.Context, e event.Event) error,
+	result func(j job, err error),
+) *pipeline {
+	return &pipeline{
+		ctx:    ctx,
+		goRun:  goRun,
+		run:    run,
+		result: result,
+		queues: map[string][]job{},
+		reads:  map[string]*readJob{},
+	}
+}
+
+// submit queues the command e, id is empty if the sender doesn't wait
+// for the result
+func (p *pipeline) submit(id string, e event.Event) {
+	j := job{e: e}
+	if id != "" {
+		j.ids = []string{id}
+	}
+	key, read := target(e)
+	if read {
+		p.read(key, j)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q, running := p.queues[key]
+	if n := len(q); n > 0 {
+		if merged, ok := merge(q[n-1].e, e); ok {
+			q[n-1] = job{e: merged, ids: append(q[n-1].ids, j.ids...)}
+			return
+		}
+	}
+	p.queues[key] = append(q, j)
+	if !running {
+		p.goRun(func(ctx context.Context) { p.work(ctx, key) })
+	}
+}
+
+// work runs the writes queued for key until there are none left
+func (p *pipeline) work(ctx context.Context, key string) {
+	for {
+		p.mu.Lock()
+		q := p.queues[key]
+		if len(q) == 0 || ctx.Err() != nil {
+			delete(p.queues, key)
+			p.mu.Unlock()
+			return
+		}
+		j := q[0]
+		p.queues[key] = q[1:]
+		p.mu.Unlock()
+
+		p.result(j, p.run(logging.WithID(ctx, strings.Join(j.ids, ",")), j.e))
+	}
+}
+
+// read runs j right away and cancels the older read of the same kind,
+// its answer would be outdated anyway. The older read's commands get
+// the result of j instead.
+func (p *pipeline) read(kind string, j job) {
+	p.mu.Lock()
+	if old, ok := p.reads[kind]; ok {
+		old.cancel(errSuperseded)
+		j.ids = append(slices.Clip(old.ids), j.ids...)
+	}
+	ctx, cancel := context.WithCancelCause(logging.WithID(p.ctx, strings.Join(j.ids, ",")))
+	r := &readJob{cancel: cancel, ids: j.ids}
+	p.reads[kind] = r
+	p.mu.Unlock()
+
+	p.goRun(func(context.Context) {
+		defer cancel(nil)
+		err := p.run(ctx, j.e)
+		p.mu.Lock()
+		replaced := p.reads[kind] != r
+		if !replaced {
+			delete(p.reads, kind)
+		}
+		p.mu.Unlock()
+		if replaced {
+			return
+		}
+		p.result(j, err)
+	})
+}
+
+// target returns the queue of a write, or the kind of a read
+func target(e event.Event) (key string, read bool) {
+	switch e.(type) {
+	case event.Playlists, event.PlaylistOpen, event.Devices, event.DetailOpen,
+		event.Podcasts, event.PodcastOpen, event.Queue, event.Lyrics:
+		return e.String(), true
+	case event.PlaylistCreate:
+		return "playlists", false
+	case event.PlaylistRename, event.PlaylistAddCurrent:
+		id, _ := e.Data()["id"].(spotify.ID)
+		return "playlist:" + string(id), false
+	case event.PlaylistRemove, event.PlaylistMove, event.PlaylistDedupe:
+		if p, ok := e.Data()["playlist"].(*playlist.Playlist); ok {
+			return "playlist:" + string(p.ID), false
+		}
+		// from a remote
+		if id, ok := e.Data()["id"].(spotify.ID); ok {
+			return "playlist:" + string(id), false
+		}
+	}
+	// everything else acts on the active device or what it plays
+	return "player", false
+}
+
+// merge merges the command b into a, which waits in front of it
+func merge(a, b event.Event) (event.Event, bool) {
+	kind, ok := event.Parse(a.String())
+	if !ok || a.String() != b.String() {
+		return nil, false
+	}
+	switch a.(type) {
+	case event.Next, event.Prev:
+		return event.New(kind, map[any]any{"count": repeats(a) + repeats(b)}), true
+	case event.Volume, event.Seek:
+		key := "volume"
+		if _, ok := a.(event.Seek); ok {
+			key = "position"
+		}
+		as, _ := a.Data()[key].(string)
+		bs, _ := b.Data()[key].(string)
+		x, xRelative, err := event.ParseRelative(as)
+		if err != nil {
+			return nil, false
+		}
+		y, yRelative, err := event.ParseRelative(bs)
+		if err != nil {
+			return nil, false
+		}
+		if !yRelative {
+			return b, true
+		}
+		v := strconv.Itoa(x + y)
+		if xRelative {
+			v = fmt.Sprintf("%+d", x+y)
+		}
+		return event.New(kind, map[any]any{key: v}), true
+	}
+	return nil, false
+}
+
+// repeats is how often a merged NEXT or PREV skips
+func repeats(e event.Event) int {
+	if n, ok := e.Data()["count"].(int); ok && n > 1 {
+		return n
+	}
+	return 1
+}
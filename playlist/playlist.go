@@ -0,0 +1,282 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// ErrConflict is returned when a playlist was changed elsewhere
+// since it was loaded.
+var ErrConflict = errors.New("playlist was modified concurrently, reload and try again")
+
+type Summary struct {
+	ID         spotify.ID
+	Name       string
+	SnapshotID string
+	Tracks     int
+}
+
+type Item struct {
+	// Position is the zero based index of the item in the playlist.
+	Position int
+	URI      spotify.URI
+	ID       spotify.ID
+	Name     string
+	Artists  string
+	ISRC     string
+	IsLocal  bool
+}
+
+type Playlist struct {
+	Summary
+	Items []Item
+}
+
+// Editor modifies playlists. Every modification of a loaded playlist is
+// checked against its snapshot ID and fails with ErrConflict if the
+// playlist has changed in the meantime.
+type Editor struct {
+	c *spotify.Client
+}
+
+func NewEditor(c *spotify.Client) Editor {
+	return Editor{c}
+}
+
+func (e Editor) List(ctx context.Context) ([]Summary, error) {
+	page, err := e.c.CurrentUsersPlaylists(ctx, spotify.Limit(50))
+	if err != nil {
+		return nil, fmt.Errorf("error listing playlists: %s", err)
+	}
+	var ps []Summary
+	for {
+		for _, p := range page.Playlists {
+			ps = append(ps, Summary{p.ID, p.Name, p.SnapshotID, int(p.Tracks.Total)})
+		}
+		err = e.c.NextPage(ctx, page)
+		if errors.Is(err, spotify.ErrNoMorePages) {
+			return ps, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing playlists: %s", err)
+		}
+	}
+}
+
+// Load fetches a playlist with all of its items.
+func (e Editor) Load(ctx context.Context, id spotify.ID) (*Playlist, error) {
+	full, err := e.c.GetPlaylist(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading playlist %s: %s", id, err)
+	}
+	p := &Playlist{
+		Summary: Summary{full.ID, full.Name, full.SnapshotID, int(full.Tracks.Total)},
+	}
+
+	page, err := e.c.GetPlaylistItems(ctx, id, spotify.Limit(100))
+	if err != nil {
+		return nil, fmt.Errorf("error loading items of %s: %s", id, err)
+	}
+	for {
+		for _, it := range page.Items {
+			p.Items = append(p.Items, newItem(len(p.Items), it))
+		}
+		err = e.c.NextPage(ctx, page)
+		if errors.Is(err, spotify.ErrNoMorePages) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error loading items of %s: %s", id, err)
+		}
+	}
+
+	// a playlist edited while paging through it is not consistent
+	if err := e.check(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newItem(pos int, it spotify.PlaylistItem) Item {
+	item := Item{Position: pos, IsLocal: it.IsLocal}
+	switch {
+	case it.Track.Track != nil:
+		t := it.Track.Track
+		names := make([]string, 0, len(t.Artists))
+		for _, a := range t.Artists {
+			names = append(names, a.Name)
+		}
+		item.URI, item.ID, item.Name = t.URI, t.ID, t.Name
+		item.Artists = strings.Join(names, ", ")
+		item.ISRC = t.ExternalIDs["isrc"]
+	case it.Track.Episode != nil:
+		ep := it.Track.Episode
+		item.URI, item.ID, item.Name = ep.URI, ep.ID, ep.Name
+		item.Artists = ep.Show.Name
+	}
+	return item
+}
+
+// check fails with ErrConflict if p is not the latest version of the playlist.
+func (e Editor) check(ctx context.Context, p *Playlist) error {
+	cur, err := e.c.GetPlaylist(ctx, p.ID, spotify.Fields("snapshot_id"))
+	if err != nil {
+		return fmt.Errorf("error checking snapshot of %s: %s", p.ID, err)
+	}
+	if cur.SnapshotID != p.SnapshotID {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (e Editor) Create(ctx context.Context, name string) (Summary, error) {
+	user, err := e.c.CurrentUser(ctx)
+	if err != nil {
+		return Summary{}, fmt.Errorf("error getting user: %s", err)
+	}
+	p, err := e.c.CreatePlaylistForUser(ctx, user.ID, name, "", false, false)
+	if err != nil {
+		return Summary{}, fmt.Errorf("error creating playlist %q: %s", name, err)
+	}
+	return Summary{p.ID, p.Name, p.SnapshotID, 0}, nil
+}
+
+func (e Editor) Rename(ctx context.Context, id spotify.ID, name string) error {
+	if err := e.c.ChangePlaylistName(ctx, id, name); err != nil {
+		return fmt.Errorf("error renaming playlist %s: %s", id, err)
+	}
+	return nil
+}
+
+// Add appends tracks to the end of a playlist. Appending does not depend
+// on positions, so it is not checked for conflicts.
+func (e Editor) Add(ctx context.Context, id spotify.ID, tracks ...spotify.ID) (string, error) {
+	snapshot, err := e.c.AddTracksToPlaylist(ctx, id, tracks...)
+	if err != nil {
+		return "", fmt.Errorf("error adding to playlist %s: %s", id, err)
+	}
+	return snapshot, nil
+}
+
+// Remove removes the items at the given positions.
+func (e Editor) Remove(ctx context.Context, p *Playlist, positions ...int) (string, error) {
+	if err := e.check(ctx, p); err != nil {
+		return "", err
+	}
+	byURI := map[spotify.URI][]int{}
+	var uris []spotify.URI
+	for _, pos := range positions {
+		if pos < 0 || pos >= len(p.Items) {
+			return "", fmt.Errorf("no item at position %d", pos)
+		}
+		it := p.Items[pos]
+		if it.IsLocal || it.URI == "" {
+			return "", fmt.Errorf("item at position %d can not be removed", pos)
+		}
+		if _, ok := byURI[it.URI]; !ok {
+			uris = append(uris, it.URI)
+		}
+		byURI[it.URI] = append(byURI[it.URI], pos)
+	}
+	tracks := make([]spotify.TrackToRemove, 0, len(uris))
+	for _, u := range uris {
+		tracks = append(tracks, spotify.TrackToRemove{URI: string(u), Positions: byURI[u]})
+	}
+	snapshot, err := e.c.RemoveTracksFromPlaylistOpt(ctx, p.ID, tracks, p.SnapshotID)
+	if err != nil {
+		return "", fmt.Errorf("error removing from playlist %s: %s", p.ID, err)
+	}
+	return snapshot, nil
+}
+
+// Move moves the item at from so that it ends up at position to.
+func (e Editor) Move(ctx context.Context, p *Playlist, from, to int) (string, error) {
+	if from < 0 || from >= len(p.Items) || to < 0 || to >= len(p.Items) {
+		return "", fmt.Errorf("can not move item %d to %d", from, to)
+	}
+	if err := e.check(ctx, p); err != nil {
+		return "", err
+	}
+	insertBefore := to
+	if to > from {
+		insertBefore = to + 1
+	}
+	snapshot, err := e.c.ReorderPlaylistTracks(ctx, p.ID, spotify.PlaylistReorderOptions{
+		RangeStart:   spotify.Numeric(from),
+		InsertBefore: spotify.Numeric(insertBefore),
+		SnapshotID:   p.SnapshotID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reordering playlist %s: %s", p.ID, err)
+	}
+	return snapshot, nil
+}
+
+type DedupeKey int
+
+const (
+	BY_ID DedupeKey = iota
+	BY_ISRC
+)
+
+func ParseDedupeKey(s string) (DedupeKey, bool) {
+	switch s {
+	case "id":
+		return BY_ID, true
+	case "isrc":
+		return BY_ISRC, true
+	}
+	return BY_ID, false
+}
+
+// Duplicates returns the positions of all items that repeat an earlier
+// item. Items without a key, e.g. local files when deduping by ISRC,
+// are never duplicates.
+func Duplicates(items []Item, by DedupeKey) []int {
+	seen := map[string]bool{}
+	var dups []int
+	for _, it := range items {
+		k := string(it.ID)
+		if by == BY_ISRC {
+			k = it.ISRC
+		}
+		if k == "" || it.IsLocal {
+			continue
+		}
+		if seen[k] {
+			dups = append(dups, it.Position)
+			continue
+		}
+		seen[k] = true
+	}
+	return dups
+}
+
+// Dedupe removes all duplicates from p and reports how many were removed.
+func (e Editor) Dedupe(ctx context.Context, p *Playlist, by DedupeKey) (int, string, error) {
+	dups := Duplicates(p.Items, by)
+	if len(dups) == 0 {
+		return 0, p.SnapshotID, nil
+	}
+	removed := len(dups)
+	// the API takes at most 100 tracks per request and each request
+	// produces a new snapshot the next one has to refer to. Removing
+	// from the back keeps the positions of the remaining batches valid.
+	snapshot := p.SnapshotID
+	for len(dups) > 0 {
+		n := max(len(dups)-100, 0)
+		batch := *p
+		batch.SnapshotID = snapshot
+		var err error
+		snapshot, err = e.Remove(ctx, &batch, dups[n:]...)
+		if err != nil {
+			return 0, "", err
+		}
+		dups = dups[:n]
+	}
+	return removed, snapshot, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/playlist"
+	"github.com/zmb3/spotify/v2"
+)
+
+// handlePlaylistEvent applies a playlist command and publishes the
+// resulting playlist state as a PLAYLIST_UPDATE event.
+func (c Client) handlePlaylistEvent(ctx context.Context, e event.Event, b Broker) error {
+	ed := playlist.NewEditor(c.Client)
+	d := e.Data()
+	update := map[any]any{}
+
+	var err error
+	var reload spotify.ID
+	listAfter := false
+
+	// the TUI edits the playlist it shows, remotes send the ID of one
+	var p *playlist.Playlist
+	switch e.(type) {
+	case event.PlaylistRemove, event.PlaylistMove, event.PlaylistDedupe:
+		var ok bool
+		if p, ok = d["playlist"].(*playlist.Playlist); !ok {
+			if p, err = ed.Load(ctx, d["id"].(spotify.ID)); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch e.(type) {
+	case event.Playlists:
+		listAfter = true
+	case event.PlaylistOpen:
+		reload = d["id"].(spotify.ID)
+	case event.PlaylistCreate:
+		var p playlist.Summary
+		p, err = ed.Create(ctx, d["name"].(string))
+		if err == nil {
+			update["message"] = fmt.Sprintf("created %s", p.Name)
+			update["created"] = p.ID
+		}
+		listAfter = true
+	case event.PlaylistRename:
+		err = ed.Rename(ctx, d["id"].(spotify.ID), d["name"].(string))
+		listAfter = true
+	case event.PlaylistAddCurrent:
+		// "tracks" are added instead of the current one if given
+		id := d["id"].(spotify.ID)
+		if tracks, ok := d["tracks"].([]spotify.ID); ok {
+			_, err = ed.Add(ctx, id, tracks...)
+			if err == nil {
+				update["message"] = fmt.Sprintf("added %d tracks", len(tracks))
+			}
+			listAfter = true
+			break
+		}
+		var track *spotify.FullTrack
+		track, err = c.currentTrack(ctx)
+		if err == nil {
+			_, err = ed.Add(ctx, id, track.ID)
+		}
+		if err == nil {
+			update["message"] = fmt.Sprintf("added %s", track.Name)
+		}
+		listAfter = true
+	case event.PlaylistRemove:
+		_, err = ed.Remove(ctx, p, d["positions"].([]int)...)
+		reload = p.ID
+	case event.PlaylistMove:
+		_, err = ed.Move(ctx, p, d["from"].(int), d["to"].(int))
+		reload = p.ID
+	case event.PlaylistDedupe:
+		var n int
+		n, _, err = ed.Dedupe(ctx, p, d["by"].(playlist.DedupeKey))
+		if err == nil {
+			update["message"] = fmt.Sprintf("removed %d duplicates", n)
+		}
+		reload = p.ID
+	}
+
+	if err != nil {
+		update["error"] = err
+		if errors.Is(err, playlist.ErrConflict) {
+			update["message"] = "playlist changed elsewhere, reloaded"
+		}
+	}
+
+	if reload != "" {
+		p, loadErr := ed.Load(ctx, reload)
+		if loadErr != nil {
+			err = errors.Join(err, loadErr)
+			update["error"] = err
+		} else {
+			update["playlist"] = p
+		}
+	}
+	if listAfter {
+		ps, listErr := ed.List(ctx)
+		if listErr != nil {
+			err = errors.Join(err, listErr)
+			update["error"] = err
+		} else {
+			update["playlists"] = ps
+		}
+	}
+
+	// a newer read publishes instead
+	if superseded(ctx) {
+		return ctx.Err()
+	}
+	b.publish(event.New(event.PLAYLIST_UPDATE, update))
+	return err
+}
+
+func (c Client) currentTrack(ctx context.Context) (*spotify.FullTrack, error) {
+	cp, err := c.PlayerCurrentlyPlaying(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading current track: %s", err)
+	}
+	if cp.Item == nil {
+		return nil, fmt.Errorf("no track is playing")
+	}
+	return cp.Item, nil
+}
@@ -0,0 +1,104 @@
+// Package podcast lists the user's saved shows and their episodes with
+// the position to resume them at.
+package podcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+type Show struct {
+	ID        spotify.ID
+	Name      string
+	Publisher string
+}
+
+// URI is the show's Spotify URI, the context to play its episodes in
+func (s Show) URI() spotify.URI {
+	return spotify.URI("spotify:show:" + string(s.ID))
+}
+
+type Episode struct {
+	ID       spotify.ID
+	URI      spotify.URI
+	Name     string
+	Released string
+	Duration time.Duration
+	// Resume is where the user stopped listening, zero if they never
+	// started or finished it
+	Resume      time.Duration
+	FullyPlayed bool
+}
+
+// Unplayed reports whether the user never started the episode
+func (e Episode) Unplayed() bool {
+	return !e.FullyPlayed && e.Resume == 0
+}
+
+// Progress describes how far the user got, e.g. "new" or "12:05 left"
+func (e Episode) Progress() string {
+	switch {
+	case e.FullyPlayed:
+		return "played"
+	case e.Unplayed():
+		return "new"
+	}
+	left := (e.Duration - e.Resume).Round(time.Second)
+	return fmt.Sprintf("%d:%02d left", int(left.Minutes()), int(left.Seconds())%60)
+}
+
+// Shows reads the user's podcasts. Resume points need the
+// user-read-playback-position scope.
+type Shows struct {
+	c *spotify.Client
+}
+
+func New(c *spotify.Client) Shows {
+	return Shows{c}
+}
+
+// List returns the saved shows
+func (s Shows) List(ctx context.Context) ([]Show, error) {
+	page, err := s.c.CurrentUsersShows(ctx, spotify.Limit(50))
+	if err != nil {
+		return nil, fmt.Errorf("error listing shows: %s", err)
+	}
+	var shows []Show
+	for {
+		for _, sh := range page.Shows {
+			shows = append(shows, Show{sh.ID, sh.Name, sh.Publisher})
+		}
+		err = s.c.NextPage(ctx, page)
+		if errors.Is(err, spotify.ErrNoMorePages) {
+			return shows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing shows: %s", err)
+		}
+	}
+}
+
+// Episodes returns the latest episodes of a show, newest first
+func (s Shows) Episodes(ctx context.Context, id spotify.ID) ([]Episode, error) {
+	page, err := s.c.GetShowEpisodes(ctx, string(id), spotify.Limit(50))
+	if err != nil {
+		return nil, fmt.Errorf("error loading episodes of %s: %s", id, err)
+	}
+	eps := make([]Episode, 0, len(page.Episodes))
+	for _, e := range page.Episodes {
+		eps = append(eps, Episode{
+			ID:          e.ID,
+			URI:         e.URI,
+			Name:        e.Name,
+			Released:    e.ReleaseDate,
+			Duration:    time.Duration(e.Duration_ms) * time.Millisecond,
+			Resume:      time.Duration(e.ResumePoint.ResumePositionMs) * time.Millisecond,
+			FullyPlayed: e.ResumePoint.FullyPlayed,
+		})
+	}
+	return eps, nil
+}
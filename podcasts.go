@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/podcast"
+	"github.com/zmb3/spotify/v2"
+)
+
+// scopeReadPlaybackPosition lets spoli read the resume points of episodes
+const scopeReadPlaybackPosition = "user-read-playback-position"
+
+// handlePodcastEvent lists shows and episodes or plays an episode. The
+// lists are published as a PODCAST_UPDATE event.
+func (c Client) handlePodcastEvent(ctx context.Context, e event.Event, b Broker) error {
+	shows := podcast.New(c.Client)
+	d := e.Data()
+	update := map[any]any{}
+
+	var err error
+	switch e.(type) {
+	case event.Podcasts:
+		var list []podcast.Show
+		list, err = shows.List(ctx)
+		if err == nil {
+			update["shows"] = list
+		}
+	case event.PodcastOpen:
+		show := d["show"].(podcast.Show)
+		var eps []podcast.Episode
+		eps, err = shows.Episodes(ctx, show.ID)
+		if err == nil {
+			update["show"] = show
+			update["episodes"] = eps
+		}
+	case event.EpisodePlay:
+		// in the show's context, so the next episode follows
+		show := d["show"].(podcast.Show)
+		ep := d["episode"].(podcast.Episode)
+		uri := show.URI()
+		opts := &spotify.PlayOptions{
+			PlaybackContext: &uri,
+			PlaybackOffset:  &spotify.PlaybackOffset{URI: ep.URI},
+		}
+		if !ep.FullyPlayed {
+			opts.PositionMs = spotify.Numeric(ep.Resume / time.Millisecond)
+		}
+		if err = c.PlayOpt(ctx, opts); err != nil {
+			return fmt.Errorf("error playing %s: %s", ep.Name, err)
+		}
+		return nil
+	}
+
+	// a newer read publishes instead
+	if superseded(ctx) {
+		return ctx.Err()
+	}
+	if err != nil {
+		update["error"] = err
+	}
+	b.publish(event.New(event.PODCAST_UPDATE, update))
+	return err
+}
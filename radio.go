@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/playlist"
+	"github.com/moritz-tiesler/spoli/radio"
+	"github.com/zmb3/spotify/v2"
+)
+
+// radioRepeatWindow is how long ago a play keeps a track off a station
+const radioRepeatWindow = 30 * 24 * time.Hour
+
+// handleRadioEvent builds a station from the current track and queues
+// it or saves it as a playlist
+func (c Client) handleRadioEvent(ctx context.Context, e event.Event, b Broker) error {
+	o := e.Data()["options"].(radio.Options)
+	track, err := c.currentTrack(ctx)
+	if err != nil {
+		return err
+	}
+	tracks, err := radio.Station(ctx, c.Client, track, o, b.recentlyPlayed())
+	if err != nil {
+		return err
+	}
+
+	if o.Save {
+		name := o.Name
+		if name == "" {
+			name = radio.Name(track, o.Seed)
+		}
+		ed := playlist.NewEditor(c.Client)
+		p, err := ed.Create(ctx, name)
+		if err != nil {
+			return err
+		}
+		ids := make([]spotify.ID, 0, len(tracks))
+		for _, t := range tracks {
+			ids = append(ids, t.ID)
+		}
+		if _, err := ed.Add(ctx, p.ID, ids...); err != nil {
+			return err
+		}
+		logBroker.InfoContext(ctx, "saved radio", "playlist", name, "tracks", len(ids))
+		return nil
+	}
+
+	for _, t := range tracks {
+		if err := c.QueueSong(ctx, t.ID); err != nil {
+			return fmt.Errorf("error queueing %s: %s", t.Name, err)
+		}
+	}
+	logBroker.InfoContext(ctx, "queued radio", "tracks", len(tracks))
+	return nil
+}
+
+// recentlyPlayed reports whether a track is in the listening history of
+// the last weeks, nothing is if there is no history
+func (b Broker) recentlyPlayed() func(spotify.ID) bool {
+	if b.history == nil {
+		return nil
+	}
+	now := time.Now()
+	plays, err := b.history.Range(now.Add(-radioRepeatWindow), now)
+	if err != nil {
+		logBroker.Warn("error reading listening history", "err", err)
+		return nil
+	}
+	played := make(map[spotify.ID]bool, len(plays))
+	for _, p := range plays {
+		played[spotify.ID(p.TrackID)] = true
+	}
+	return func(id spotify.ID) bool { return played[id] }
+}
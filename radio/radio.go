@@ -0,0 +1,167 @@
+// Package radio builds stations of recommended tracks seeded with the
+// current track, its artist or the artist's genres.
+package radio
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Seed is what the recommendations start from
+type Seed string
+
+const (
+	TRACK  Seed = "track"
+	ARTIST Seed = "artist"
+	GENRES Seed = "genres"
+)
+
+var Seeds = []string{string(TRACK), string(ARTIST), string(GENRES)}
+
+const (
+	defaultSize = 25
+	// maxSize is the most tracks the API recommends at once
+	maxSize = 100
+)
+
+// Options configure a station. Zero attributes aren't tuned.
+type Options struct {
+	Seed       Seed
+	Energy     float64 // 0 to 1
+	Tempo      float64 // in BPM
+	Popularity int     // 0 to 100
+	Size       int
+	// Save saves the station as a playlist named Name instead of
+	// queueing it. An empty name is made up from the seed.
+	Save bool
+	Name string
+}
+
+// Parse reads options from arguments like
+//
+//	artist energy=0.8 tempo=120 popularity=40 size=30 playlist="name"
+//
+// All arguments are optional, the seed defaults to the track.
+func Parse(args []string) (Options, error) {
+	o := Options{Seed: TRACK, Size: defaultSize}
+	for i, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+		var err error
+		switch key {
+		case string(TRACK), string(ARTIST), string(GENRES):
+			if hasValue {
+				return o, fmt.Errorf("%s takes no value", key)
+			}
+			o.Seed = Seed(key)
+		case "energy":
+			o.Energy, err = parseFloat(key, value, 0, 1)
+		case "tempo":
+			o.Tempo, err = parseFloat(key, value, 1, 300)
+		case "popularity":
+			o.Popularity, err = parseInt(key, value, 0, 100)
+		case "size":
+			o.Size, err = parseInt(key, value, 1, maxSize)
+		case "playlist":
+			// the name may contain spaces
+			o.Save = true
+			o.Name = strings.Trim(strings.Join(append([]string{value}, args[i+1:]...), " "), `" `)
+			return o, nil
+		default:
+			return o, fmt.Errorf("unknown radio option %q", arg)
+		}
+		if err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+func parseFloat(key, value string, lo, hi float64) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < lo || f > hi {
+		return 0, fmt.Errorf("%s must be a number from %g to %g", key, lo, hi)
+	}
+	return f, nil
+}
+
+func parseInt(key, value string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s must be a whole number from %d to %d", key, lo, hi)
+	}
+	return n, nil
+}
+
+// Station returns up to o.Size recommendations for the track. Tracks for
+// which skip returns true, e.g. because they were played recently, are
+// left out.
+func Station(ctx context.Context, c *spotify.Client, track *spotify.FullTrack, o Options, skip func(spotify.ID) bool) ([]spotify.SimpleTrack, error) {
+	seeds, err := seedsOf(ctx, c, track, o.Seed)
+	if err != nil {
+		return nil, err
+	}
+	attrs := spotify.NewTrackAttributes()
+	if o.Energy > 0 {
+		attrs.TargetEnergy(o.Energy)
+	}
+	if o.Tempo > 0 {
+		attrs.TargetTempo(o.Tempo)
+	}
+	if o.Popularity > 0 {
+		attrs.TargetPopularity(o.Popularity)
+	}
+
+	// ask for more than needed, some are skipped
+	recs, err := c.GetRecommendations(ctx, seeds, attrs, spotify.Limit(maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("error getting recommendations: %s", err)
+	}
+	var tracks []spotify.SimpleTrack
+	for _, t := range recs.Tracks {
+		if t.ID == track.ID || (skip != nil && skip(t.ID)) {
+			continue
+		}
+		tracks = append(tracks, t)
+		if len(tracks) == o.Size {
+			break
+		}
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("no recommendations left that weren't played recently")
+	}
+	return tracks, nil
+}
+
+// Name is the default name of a station's playlist
+func Name(track *spotify.FullTrack, seed Seed) string {
+	name := track.Name
+	if seed != TRACK && len(track.Artists) > 0 {
+		name = track.Artists[0].Name
+	}
+	return "Radio: " + name
+}
+
+func seedsOf(ctx context.Context, c *spotify.Client, track *spotify.FullTrack, seed Seed) (spotify.Seeds, error) {
+	if seed == TRACK {
+		return spotify.Seeds{Tracks: []spotify.ID{track.ID}}, nil
+	}
+	if len(track.Artists) == 0 {
+		return spotify.Seeds{}, fmt.Errorf("the current track has no artist")
+	}
+	artist := track.Artists[0].ID
+	if seed == ARTIST {
+		return spotify.Seeds{Artists: []spotify.ID{artist}}, nil
+	}
+	full, err := c.GetArtist(ctx, artist)
+	if err != nil {
+		return spotify.Seeds{}, fmt.Errorf("error reading genres of %s: %s", track.Artists[0].Name, err)
+	}
+	if len(full.Genres) == 0 {
+		return spotify.Seeds{}, fmt.Errorf("%s has no genres, try the artist instead", full.Name)
+	}
+	return spotify.Seeds{Genres: full.Genres[:min(len(full.Genres), spotify.MaxNumberOfSeeds)]}, nil
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/library"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/moritz-tiesler/spoli/playlist"
+	"github.com/zmb3/spotify/v2"
+)
+
+// remoteCommands are the events a web remote or the CLI may send, with
+// the data keys each one takes. Lists, like the positions of
+// playlistRemove, are separated by spaces.
+var remoteCommands = map[event.Kind][]string{
+	event.TOGGLE_PLAY:          nil,
+	event.NEXT:                 nil,
+	event.PREV:                 nil,
+	event.VOLUME:               {"volume"},
+	event.SEEK:                 {"position"},
+	event.SHUFFLE:              {"state"},
+	event.REPEAT:               {"state"},
+	event.DEVICE:               {"name"},
+	event.DEVICES:              nil,
+	event.LINK:                 {"link", "queue"},
+	event.LIBRARY_SAVE:         {"kind"},
+	event.LIBRARY_REMOVE:       {"kind"},
+	event.PLAYLISTS:            nil,
+	event.PLAYLIST_OPEN:        {"id"},
+	event.PLAYLIST_CREATE:      {"name"},
+	event.PLAYLIST_RENAME:      {"id", "name"},
+	event.PLAYLIST_ADD_CURRENT: {"id", "tracks"},
+	event.PLAYLIST_REMOVE:      {"id", "positions"},
+	event.PLAYLIST_MOVE:        {"id", "from", "to"},
+	event.PLAYLIST_DEDUPE:      {"id", "by"},
+}
+
+// remoteCommand is the body of POST /api/command, e.g.
+//
+//	{"event": "volume", "data": {"volume": "+10"}}
+type remoteCommand struct {
+	Event string            `json:"event"`
+	Data  map[string]string `json:"data"`
+}
+
+func (c remoteCommand) toEvent() (event.Event, error) {
+	kind, ok := event.Parse(c.Event)
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", c.Event)
+	}
+	keys, ok := remoteCommands[kind]
+	if !ok {
+		return nil, fmt.Errorf("event %q can't be sent remotely", c.Event)
+	}
+	if len(keys) == 0 {
+		return event.New(kind, nil), nil
+	}
+
+	get := func(key string) string {
+		return strings.TrimSpace(c.Data[key])
+	}
+	v := get(keys[0])
+	data := map[any]any{keys[0]: v}
+	switch kind {
+	case event.VOLUME, event.SEEK:
+		if _, _, err := event.ParseRelative(v); err != nil {
+			return nil, err
+		}
+	case event.SHUFFLE:
+		if !slices.Contains([]string{"", "on", "off"}, v) {
+			return nil, fmt.Errorf("shuffle must be on or off")
+		}
+	case event.REPEAT:
+		if !slices.Contains([]string{"", "off", "track", "context"}, v) {
+			return nil, fmt.Errorf("repeat must be off, track or context")
+		}
+	case event.DEVICE:
+		if v == "" {
+			return nil, fmt.Errorf("device needs a name")
+		}
+	case event.LINK:
+		l, err := link.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		queue := get("queue") == "true"
+		if _, err := l.Action(queue); err != nil {
+			return nil, err
+		}
+		data["link"], data["queue"] = l, queue
+	case event.LIBRARY_SAVE, event.LIBRARY_REMOVE:
+		// the kind of the current item without one
+		kind, ok := library.ParseKind(v)
+		if v != "" && !ok {
+			return nil, fmt.Errorf("unknown kind %q", v)
+		}
+		data["kind"] = kind
+	case event.PLAYLIST_CREATE:
+		if v == "" {
+			return nil, fmt.Errorf("%s needs a name", c.Event)
+		}
+	default:
+		// the playlist commands
+		if v == "" {
+			return nil, fmt.Errorf("%s needs an id", c.Event)
+		}
+		data["id"] = spotify.ID(v)
+		var err error
+		switch kind {
+		case event.PLAYLIST_RENAME:
+			data["name"] = get("name")
+			if data["name"] == "" {
+				err = fmt.Errorf("%s needs a name", c.Event)
+			}
+		case event.PLAYLIST_ADD_CURRENT:
+			if tracks := strings.Fields(get("tracks")); len(tracks) > 0 {
+				ids := make([]spotify.ID, 0, len(tracks))
+				for _, t := range tracks {
+					ids = append(ids, spotify.ID(t))
+				}
+				data["tracks"] = ids
+			}
+		case event.PLAYLIST_REMOVE:
+			positions := strings.Fields(get("positions"))
+			data["positions"], err = atois(positions)
+			if len(positions) == 0 {
+				err = fmt.Errorf("%s needs positions", c.Event)
+			}
+		case event.PLAYLIST_MOVE:
+			var pos []int
+			pos, err = atois([]string{get("from"), get("to")})
+			if err == nil {
+				data["from"], data["to"] = pos[0], pos[1]
+			}
+		case event.PLAYLIST_DEDUPE:
+			by, ok := playlist.ParseDedupeKey(get("by"))
+			if !ok {
+				err = fmt.Errorf("unknown dedupe key %q", get("by"))
+			}
+			data["by"] = by
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return event.New(kind, data), nil
+}
+
+// playlistPayload is a PLAYLIST_UPDATE as remotes see it
+type playlistPayload struct {
+	Playlists []playlist.Summary `json:"playlists,omitempty"`
+	Playlist  *playlist.Playlist `json:"playlist,omitempty"`
+	// Created is the ID of the playlist created by playlistCreate
+	Created spotify.ID `json:"created,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+func playlistPayloadOf(e event.PlaylistUpdate) playlistPayload {
+	d := e.Data()
+	var p playlistPayload
+	p.Playlists, _ = d["playlists"].([]playlist.Summary)
+	p.Playlist, _ = d["playlist"].(*playlist.Playlist)
+	p.Created, _ = d["created"].(spotify.ID)
+	p.Message, _ = d["message"].(string)
+	if err, ok := d["error"].(error); ok {
+		p.Error = err.Error()
+	}
+	return p
+}
+
+// nowPlaying is the player state as the web remote sees it
+type nowPlaying struct {
+	Active   bool   `json:"active"`
+	Playing  bool   `json:"playing"`
+	Type     string `json:"type,omitempty"` // "track" or "episode"
+	Track    string `json:"track,omitempty"`
+	Artists  string `json:"artists,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Image    string `json:"image,omitempty"`
+	Progress int    `json:"progress_ms"`
+	Duration int    `json:"duration_ms"`
+	Shuffle  bool   `json:"shuffle"`
+	Repeat   string `json:"repeat"`
+	Volume   int    `json:"volume"`
+	Device   string `json:"device,omitempty"`
+}
+
+func nowPlayingOf(ps *spotify.PlayerState) nowPlaying {
+	if ps == nil {
+		return nowPlaying{}
+	}
+	np := nowPlaying{
+		Active:   true,
+		Playing:  ps.Playing,
+		Progress: int(ps.Progress),
+		Shuffle:  ps.ShuffleState,
+		Repeat:   ps.RepeatState,
+		Volume:   int(ps.Device.Volume),
+		Device:   ps.Device.Name,
+	}
+	if t := ps.Item; t != nil {
+		np.Type = t.Type
+		np.Track = t.Name
+		np.Album = t.Album.Name
+		np.Duration = int(t.Duration)
+		names := make([]string, 0, len(t.Artists))
+		for _, a := range t.Artists {
+			names = append(names, a.Name)
+		}
+		np.Artists = strings.Join(names, ", ")
+		if len(t.Album.Images) > 0 {
+			np.Image = t.Album.Images[0].URL
+		}
+	}
+	return np
+}
+
+// remotePayload is what the web remote is sent for e, if anything
+func remotePayload(e event.Event) (any, bool) {
+	switch e := e.(type) {
+	case event.StateChange:
+		ps, _ := e.Data()["state"].(*spotify.PlayerState)
+		return nowPlayingOf(ps), true
+	case event.DeviceList:
+		return map[string]any{"devices": e.Data()["devices"]}, true
+	case event.PlaylistUpdate:
+		return playlistPayloadOf(e), true
+	case event.Result:
+		id, _ := e.Data()["id"].(string)
+		if !strings.HasPrefix(id, "web-") {
+			return nil, false
+		}
+		return map[string]any{"id": id, "command": e.Data()["command"], "error": e.Data()["error"]}, true
+	}
+	return nil, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logServer.Warn("error writing response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// serveCommand decodes a command and queues it on the broker's sink
+func (b *Broker) serveCommand(w http.ResponseWriter, r *http.Request) {
+	if b.spotifyClient() == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("not logged in"))
+		return
+	}
+	var c remoteCommand
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid command: %s", err))
+		return
+	}
+	e, err := c.toEvent()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// the outcome follows as a "result" event with the same id
+	cmd := event.NewCommand("web", e)
+	select {
+	case b.Sink() <- cmd:
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued", "id": cmd.ID})
+	case <-time.After(2 * time.Second):
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("busy, try again"))
+	case <-r.Context().Done():
+	}
+}
+
+// serveEvents streams the player state to a web remote as server-sent
+// events, named like the broker events, e.g. "stateChange".
+func (b *Broker) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if b.spotifyClient() == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("not logged in"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	events, cancel := b.hub.subscribe(true)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// the remote offers the devices to transfer playback to
+	select {
+	case b.Sink() <- event.New(event.DEVICES, nil):
+	default:
+	}
+
+	// keeps proxies and phones from closing an idle connection
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			payload, ok := remotePayload(e)
+			if !ok {
+				continue
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				logServer.Warn("error encoding event", "event", e, "err", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// statusPage tells the user of the web remote why it can't be shown
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    {{if .Refresh}}<meta http-equiv="refresh" content="5">{{end}}
+    <title>spoli - {{.Title}}</title>
+    <style>
+        body { background-color: #333; color: #eee; font-family: monospace; padding: 1rem; }
+    </style>
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+    <p>{{.Message}}</p>
+    {{if .Refresh}}<p>This page reloads by itself.</p>{{end}}
+</body>
+</html>
+`))
+
+func writePage(w http.ResponseWriter, status int, title, msg string, refresh bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := statusPage.Execute(w, struct {
+		Title, Message string
+		Refresh        bool
+	}{title, msg, refresh})
+	if err != nil {
+		logServer.Warn("error writing page", "err", err)
+	}
+}
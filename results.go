@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/zmb3/spotify/v2"
+)
+
+var (
+	errNotLoggedIn    = errors.New("not logged in")
+	errNoActiveDevice = errors.New("no active device")
+)
+
+// handle runs a command from the Sink
+func (b *Broker) handle(ctx context.Context, e event.Event) error {
+	client := b.spotifyClient()
+	if client == nil {
+		return errNotLoggedIn
+	}
+	// spoli's own devices are driven directly while they play
+	if b.drive(e) {
+		return nil
+	}
+	return client.handlePlayerEvent(ctx, e, *b)
+}
+
+// report publishes the result of j for the commands merged into it
+func (b *Broker) report(j job, err error) {
+	if err != nil {
+		logBroker.Warn("command failed", "event", j.e, "ids", j.ids, "err", err)
+	}
+	for _, id := range j.ids {
+		b.publish(event.NewResult(id, j.e.String(), describeError(err)))
+	}
+}
+
+// describeError turns the error of a command into a short message for
+// the user, empty if there was none. Most handlers wrap the errors of
+// the Web API with %s, so the messages are matched as well.
+func describeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var se spotify.Error
+	status := 0
+	if errors.As(err, &se) {
+		status = se.Status
+	}
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.Canceled), strings.Contains(msg, context.Canceled.Error()):
+		return "canceled"
+	case errors.Is(err, errNotLoggedIn):
+		return "not logged in to Spotify yet"
+	case errors.Is(err, errNoActiveDevice), strings.Contains(msg, "No active device"):
+		return "no active device, start playback somewhere or pick one with :device"
+	// Spotify answers 403 for more than a missing subscription, only the
+	// message tells them apart
+	case strings.Contains(msg, "Premium required"):
+		return "Spotify Premium required"
+	case strings.Contains(msg, "Restriction violated"):
+		return "not allowed right now, e.g. at the end of the queue or in a restricted context"
+	case strings.Contains(msg, "Insufficient client scope"):
+		return "spoli lacks the permission for this, log in again"
+	case status == http.StatusTooManyRequests, strings.Contains(msg, "rate limit"):
+		return "rate limited by Spotify, try again in a moment"
+	}
+	return msg
+}
+
+// commandID is the ID of e if it is a command
+func commandID(e event.Event) string {
+	if c, ok := e.(event.Command); ok {
+		return c.ID
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const sessionCookie = "spoli_session"
+
+// session guards the local API with a secret created at startup. The
+// browser gets it as a cookie, either when Spotify redirects back after
+// the login or by opening the remote link spoli prints.
+type session struct {
+	secret string
+	// origins may call the API from a browser
+	origins []string
+}
+
+func newSession(origins []string) (*session, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("error creating session secret: %s", err)
+	}
+	return &session{secret: secret, origins: origins}, nil
+}
+
+// randomToken returns 32 random bytes in hex
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// valid reports whether r carries the secret, as cookie or bearer token.
+// Either one will do, a stale cookie doesn't hide a valid token.
+func (s *session) valid(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.matches(token) {
+		return true
+	}
+	c, err := r.Cookie(sessionCookie)
+	return err == nil && s.matches(c.Value)
+}
+
+func (s *session) matches(got string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.secret)) == 1
+}
+
+// save writes the secret to path, readable only by the user
+func (s *session) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error saving session: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(s.secret), 0o600); err != nil {
+		return fmt.Errorf("error saving session: %s", err)
+	}
+	return nil
+}
+
+func (s *session) setCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.secret,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+	})
+}
+
+// remoteURL opens the web remote and hands it the secret
+func (s *session) remoteURL(origin string) string {
+	return origin + "/remote?key=" + s.secret
+}
+
+// login sets the cookie if the request has the secret in its "key"
+// parameter and redirects to the same URL without it, so it doesn't
+// stay in the browser's history
+func (s *session) login(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.matches(key) {
+			http.Error(w, "invalid key", http.StatusUnauthorized)
+			return
+		}
+		s.setCookie(w)
+		u := *r.URL
+		q := u.Query()
+		q.Del("key")
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusSeeOther)
+	})
+}
+
+// require rejects requests without the secret
+func (s *session) require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.valid(r) {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid session, open the remote link printed by spoli"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowed reports whether a browser page from origin may call the API.
+// Pages served by spoli itself, e.g. the remote opened on a phone, are
+// same-origin and always allowed.
+func (s *session) allowed(origin string, r *http.Request) bool {
+	return origin == "http://"+r.Host || slices.Contains(s.origins, origin)
+}
+
+// cors answers browsers on the allow-list and refuses cross-origin
+// requests from everyone else. Requests without an Origin header, like
+// curl's, are left to require.
+func (s *session) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.allowed(origin, r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
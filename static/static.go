@@ -0,0 +1,98 @@
+// Package static holds the files of the web player. They are embedded
+// into the binary so spoli runs from any directory.
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed *.html
+var files embed.FS
+
+// Handler serves the web player files. If dir is not empty the files are
+// read from there instead, to try changes without rebuilding.
+//
+// Embedded files are served with an ETag so browsers revalidate them
+// cheaply. Files from dir are never cached.
+func Handler(dir string) http.Handler {
+	if dir != "" {
+		fileServer := http.FileServer(http.Dir(dir))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+			fileServer.ServeHTTP(w, r)
+		})
+	}
+	return newEmbedded(files)
+}
+
+type asset struct {
+	data []byte
+	etag string
+}
+
+// embedded serves files from memory, hashed once at startup
+type embedded struct {
+	assets  map[string]asset
+	modTime time.Time
+}
+
+func newEmbedded(fsys fs.FS) embedded {
+	e := embedded{assets: map[string]asset{}, modTime: startTime()}
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		e.assets[name] = asset{data: data, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+		return nil
+	})
+	return e
+}
+
+func (e embedded) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "player.html"
+	}
+	a, ok := e.assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", a.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	// ServeContent answers If-None-Match with 304 and sets the content type
+	http.ServeContent(w, r, name, e.modTime, bytes.NewReader(a.data))
+}
+
+// startTime stands in for the modification time of the embedded files,
+// which embed doesn't record. The binary's own time is the best guess.
+func startTime() time.Time {
+	exe, err := os.Executable()
+	if err != nil {
+		return time.Now()
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return time.Now()
+	}
+	return fi.ModTime()
+}
@@ -0,0 +1,199 @@
+// Package status renders the player state for status bars like tmux,
+// polybar, waybar and i3blocks.
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// State is the player state as the events stream of spoli sends it
+type State struct {
+	Active   bool   `json:"active"`
+	Playing  bool   `json:"playing"`
+	Type     string `json:"type"`
+	Track    string `json:"track"`
+	Artists  string `json:"artists"`
+	Album    string `json:"album"`
+	Progress int    `json:"progress_ms"`
+	Duration int    `json:"duration_ms"`
+	Shuffle  bool   `json:"shuffle"`
+	Repeat   string `json:"repeat"`
+	Volume   int    `json:"volume"`
+	Device   string `json:"device"`
+}
+
+// Info is what templates can use, e.g. {{.Artist}} - {{.Title}}
+type Info struct {
+	Artist string
+	// Title scrolls if it is longer than the width
+	Title  string
+	Album  string
+	Device string
+	Type   string // "track" or "episode"
+	// Status is "playing", "paused" or "stopped"
+	Status   string
+	Playing  bool
+	Progress string // m:ss
+	Duration string
+	Percent  int
+	Volume   int
+	Shuffle  bool
+	Repeat   string
+}
+
+// At returns the info of s, received at since, as of now. The progress
+// moves on while playing.
+func (s State) At(since, now time.Time) Info {
+	if !s.Active || s.Track == "" {
+		return Info{Status: "stopped"}
+	}
+	progress := time.Duration(s.Progress) * time.Millisecond
+	duration := time.Duration(s.Duration) * time.Millisecond
+	status := "paused"
+	if s.Playing {
+		status = "playing"
+		progress = min(progress+now.Sub(since), duration)
+	}
+	info := Info{
+		Artist:   s.Artists,
+		Title:    s.Track,
+		Album:    s.Album,
+		Device:   s.Device,
+		Type:     s.Type,
+		Status:   status,
+		Playing:  s.Playing,
+		Progress: clock(progress),
+		Duration: clock(duration),
+		Volume:   s.Volume,
+		Shuffle:  s.Shuffle,
+		Repeat:   s.Repeat,
+	}
+	if duration > 0 {
+		info.Percent = int(progress * 100 / duration)
+	}
+	return info
+}
+
+func clock(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// DefaultFormat is the text shown if no format is given
+const DefaultFormat = "{{.Artist}} - {{.Title}}"
+
+// Presets are the output formats of the status bars that need more than
+// a line of text
+var Presets = []string{"plain", "tmux", "waybar", "i3bar"}
+
+// Renderer formats the info for a status bar
+type Renderer struct {
+	preset string
+	tmpl   *template.Template
+}
+
+// NewRenderer returns a renderer for one of the Presets, with the text
+// from the template format. The tmux preset adds a play or pause sign if
+// the format is empty.
+func NewRenderer(preset, format string) (*Renderer, error) {
+	switch preset {
+	case "plain", "waybar", "i3bar":
+	case "tmux":
+		if format == "" {
+			format = "{{if .Playing}}▶{{else}}⏸{{end}} " + DefaultFormat
+		}
+	default:
+		return nil, fmt.Errorf("unknown preset %q, expected one of %s", preset, strings.Join(Presets, ", "))
+	}
+	if format == "" {
+		format = DefaultFormat
+	}
+	tmpl, err := template.New("status").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format: %s", err)
+	}
+	// fail on unknown fields now rather than with the first track
+	if err := tmpl.Execute(&bytes.Buffer{}, Info{}); err != nil {
+		return nil, fmt.Errorf("invalid format: %s", err)
+	}
+	return &Renderer{preset: preset, tmpl: tmpl}, nil
+}
+
+// Header is printed once before the first line, empty if the preset has
+// none
+func (r *Renderer) Header() string {
+	if r.preset == "i3bar" {
+		// the status lines follow as an endless JSON array
+		return "{\"version\":1}\n["
+	}
+	return ""
+}
+
+// Render returns the line for info. Nothing is shown while stopped.
+func (r *Renderer) Render(info Info) (string, error) {
+	var text string
+	if info.Status != "stopped" {
+		if r.preset == "tmux" {
+			// # starts a tmux format
+			info.Artist = strings.ReplaceAll(info.Artist, "#", "##")
+			info.Title = strings.ReplaceAll(info.Title, "#", "##")
+			info.Album = strings.ReplaceAll(info.Album, "#", "##")
+		}
+		var b strings.Builder
+		if err := r.tmpl.Execute(&b, info); err != nil {
+			return "", fmt.Errorf("error formatting status: %s", err)
+		}
+		text = strings.ReplaceAll(b.String(), "\n", " ")
+	}
+
+	switch r.preset {
+	case "waybar":
+		return marshal(map[string]any{
+			"text":       text,
+			"alt":        info.Status,
+			"class":      info.Status,
+			"tooltip":    tooltip(info),
+			"percentage": info.Percent,
+		})
+	case "i3bar":
+		line, err := marshal([]map[string]any{{"name": "spoli", "full_text": text}})
+		return line + ",", err
+	}
+	return text, nil
+}
+
+func tooltip(info Info) string {
+	if info.Status == "stopped" {
+		return "Nothing playing"
+	}
+	return fmt.Sprintf("%s\n%s\n%s\n%s / %s on %s", info.Title, info.Artist, info.Album, info.Progress, info.Duration, info.Device)
+}
+
+func marshal(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error encoding status: %s", err)
+	}
+	return string(b), nil
+}
+
+// Scroll returns width runes of s starting at offset, wrapping around
+// with a gap. Strings that fit are returned as they are.
+func Scroll(s string, width, offset int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	loop := append(runes, []rune(" · ")...)
+	start := offset % len(loop)
+	out := make([]rune, 0, width)
+	for i := range width {
+		out = append(out, loop[(start+i)%len(loop)])
+	}
+	return string(out)
+}
@@ -0,0 +1,111 @@
+package status
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// stateEvent is the name of the player state events in the stream
+const stateEvent = "stateChange"
+
+// ErrUnreachable is returned when no spoli answers at the url
+var ErrUnreachable = errors.New("spoli isn't running")
+
+// maxEvent is the size of the largest event read, a whole playlist
+// may be in one
+const maxEvent = 16 << 20
+
+// Events are the server-sent events of spoli's /api/events
+type Events struct {
+	body io.ReadCloser
+	sc   *bufio.Scanner
+}
+
+// Connect opens the events at url. token is the session secret of the
+// running spoli.
+func Connect(ctx context.Context, url, token string) (*Events, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to spoli: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnreachable, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusServiceUnavailable:
+		resp.Body.Close()
+		return nil, fmt.Errorf("spoli isn't logged in to Spotify yet")
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("error connecting to spoli: %s", resp.Status)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, maxEvent)
+	return &Events{body: resp.Body, sc: sc}, nil
+}
+
+// Next returns the name and data of the next event. It returns io.EOF
+// when the stream ends.
+func (e *Events) Next() (name, data string, err error) {
+	for e.sc.Scan() {
+		line := e.sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && name != "":
+			// the end of an event
+			return name, data, nil
+		}
+	}
+	if err := e.sc.Err(); err != nil {
+		return "", "", fmt.Errorf("error reading from spoli: %s", err)
+	}
+	return "", "", io.EOF
+}
+
+func (e *Events) Close() error {
+	return e.body.Close()
+}
+
+// Stream reads the events at url and passes every player state to f,
+// until the stream or ctx ends. token is the session secret of the
+// running spoli.
+func Stream(ctx context.Context, url, token string, f func(State)) error {
+	events, err := Connect(ctx, url, token)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+	for {
+		name, data, err := events.Next()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if name != stateEvent {
+			continue
+		}
+		var s State
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return fmt.Errorf("error decoding state: %s", err)
+		}
+		f(s)
+	}
+}
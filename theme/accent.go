@@ -0,0 +1,71 @@
+package theme
+
+import (
+	"image"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// samples is about the number of pixels per side looked at
+const samples = 64
+
+// Accent returns an accent color from the dominant color of img, like a
+// cover, lightened for dark and darkened for light backgrounds. Covers
+// in black, white and gray have none.
+func Accent(img image.Image) (lipgloss.AdaptiveColor, bool) {
+	c, ok := dominant(img)
+	if !ok {
+		return lipgloss.AdaptiveColor{}, false
+	}
+	h, chroma, l := c.Hcl()
+	// readable on the background, and colorful enough to stand out
+	chroma = max(chroma, 0.4)
+	dark := colorful.Hcl(h, chroma, max(l, 0.7)).Clamped()
+	light := colorful.Hcl(h, chroma, min(l, 0.45)).Clamped()
+	return lipgloss.AdaptiveColor{Dark: dark.Hex(), Light: light.Hex()}, true
+}
+
+// dominant returns the average of the most common of the saturated
+// colors of img
+func dominant(img image.Image) (colorful.Color, bool) {
+	type bucket struct {
+		r, g, b float64
+		n       int
+	}
+	var buckets [512]bucket
+	bounds := img.Bounds()
+	stepX := max(bounds.Dx()/samples, 1)
+	stepY := max(bounds.Dy()/samples, 1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			c, ok := colorful.MakeColor(img.At(x, y))
+			if !ok {
+				continue
+			}
+			_, s, v := c.Hsv()
+			if s < 0.25 || v < 0.2 {
+				continue
+			}
+			r, g, b := c.RGB255()
+			// 8 levels per channel
+			i := int(r>>5)<<6 | int(g>>5)<<3 | int(b>>5)
+			buckets[i].r += c.R
+			buckets[i].g += c.G
+			buckets[i].b += c.B
+			buckets[i].n++
+		}
+	}
+	best := 0
+	for i := range buckets {
+		if buckets[i].n > buckets[best].n {
+			best = i
+		}
+	}
+	bk := buckets[best]
+	if bk.n == 0 {
+		return colorful.Color{}, false
+	}
+	n := float64(bk.n)
+	return colorful.Color{R: bk.r / n, G: bk.g / n, B: bk.b / n}, true
+}
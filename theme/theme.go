@@ -0,0 +1,245 @@
+// Package theme holds the color palettes of the TUI. Every color has a
+// variant for dark and one for light terminal backgrounds.
+package theme
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Palette colors the parts of the TUI
+type Palette struct {
+	// Accent marks the focused pane, the selected row and the current tab
+	Accent lipgloss.AdaptiveColor
+	// Muted is for borders, hints and other less important text
+	Muted lipgloss.AdaptiveColor
+	Error lipgloss.AdaptiveColor
+	// Liked is the heart of saved tracks, albums and shows
+	Liked lipgloss.AdaptiveColor
+}
+
+// Default is the palette used if none is configured. Its ANSI colors
+// follow the color scheme of the terminal.
+const Default = "default"
+
+// Builtin are the palettes spoli comes with
+var Builtin = map[string]Palette{
+	Default: {
+		Accent: lipgloss.AdaptiveColor{Light: "4", Dark: "12"},
+		Muted:  lipgloss.AdaptiveColor{Light: "8", Dark: "8"},
+		Error:  lipgloss.AdaptiveColor{Light: "1", Dark: "9"},
+		Liked:  lipgloss.AdaptiveColor{Light: "5", Dark: "13"},
+	},
+	"nord": {
+		Accent: lipgloss.AdaptiveColor{Light: "#5E81AC", Dark: "#88C0D0"},
+		Muted:  lipgloss.AdaptiveColor{Light: "#7B88A1", Dark: "#4C566A"},
+		Error:  lipgloss.AdaptiveColor{Light: "#BF616A", Dark: "#BF616A"},
+		Liked:  lipgloss.AdaptiveColor{Light: "#B48EAD", Dark: "#B48EAD"},
+	},
+	"gruvbox": {
+		Accent: lipgloss.AdaptiveColor{Light: "#B57614", Dark: "#FABD2F"},
+		Muted:  lipgloss.AdaptiveColor{Light: "#A89984", Dark: "#665C54"},
+		Error:  lipgloss.AdaptiveColor{Light: "#9D0006", Dark: "#FB4934"},
+		Liked:  lipgloss.AdaptiveColor{Light: "#8F3F71", Dark: "#D3869B"},
+	},
+	"solarized": {
+		Accent: lipgloss.AdaptiveColor{Light: "#268BD2", Dark: "#268BD2"},
+		Muted:  lipgloss.AdaptiveColor{Light: "#93A1A1", Dark: "#586E75"},
+		Error:  lipgloss.AdaptiveColor{Light: "#DC322F", Dark: "#DC322F"},
+		Liked:  lipgloss.AdaptiveColor{Light: "#D33682", Dark: "#D33682"},
+	},
+	"dracula": {
+		Accent: lipgloss.AdaptiveColor{Light: "#7C4DCC", Dark: "#BD93F9"},
+		Muted:  lipgloss.AdaptiveColor{Light: "#8A93B8", Dark: "#6272A4"},
+		Error:  lipgloss.AdaptiveColor{Light: "#D03030", Dark: "#FF5555"},
+		Liked:  lipgloss.AdaptiveColor{Light: "#C0399A", Dark: "#FF79C6"},
+	},
+}
+
+// Palettes are named palettes
+type Palettes map[string]Palette
+
+// Names are the sorted names of the palettes
+func (ps Palettes) Names() []string {
+	names := make([]string, 0, len(ps))
+	for name := range ps {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Get returns the palette called name
+func (ps Palettes) Get(name string) (Palette, error) {
+	p, ok := ps[name]
+	if !ok {
+		return Palette{}, fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(ps.Names(), ", "))
+	}
+	return p, nil
+}
+
+// Load reads the palettes of the file at path on top of the builtin
+// ones. A missing file is not an error. A section starts a palette, its
+// colors are ANSI numbers or hex codes, with an optional second color
+// for light backgrounds. Unset colors come from the default palette.
+//
+//	[mine]
+//	accent = #FF8800 #AA5500
+//	muted = 8
+func Load(path string) (Palettes, error) {
+	ps := Palettes{}
+	for name, p := range Builtin {
+		ps[name] = p
+	}
+	if path == "" {
+		return ps, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening themes: %s", err)
+	}
+	defer f.Close()
+	if err := ps.read(f); err != nil {
+		return nil, fmt.Errorf("error reading themes %s: %s", path, err)
+	}
+	return ps, nil
+}
+
+func (ps Palettes) read(r io.Reader) error {
+	var name string
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := ps[name]; !ok {
+				ps[name] = Builtin[Default]
+			}
+			continue
+		}
+		if name == "" {
+			return fmt.Errorf("line %d: expected a [name] before the colors", n)
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected 'key = value'", n)
+		}
+		c, err := parseColor(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+		p := ps[name]
+		switch strings.TrimSpace(k) {
+		case "accent":
+			p.Accent = c
+		case "muted":
+			p.Muted = c
+		case "error":
+			p.Error = c
+		case "liked":
+			p.Liked = c
+		default:
+			return fmt.Errorf("line %d: unknown color %q, expected accent, muted, error or liked", n, strings.TrimSpace(k))
+		}
+		ps[name] = p
+	}
+	return sc.Err()
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// parseColor reads "dark [light]"
+func parseColor(v string) (lipgloss.AdaptiveColor, error) {
+	fields := strings.Fields(v)
+	if len(fields) == 0 || len(fields) > 2 {
+		return lipgloss.AdaptiveColor{}, fmt.Errorf("expected one or two colors, got %q", v)
+	}
+	for _, f := range fields {
+		if n, err := strconv.Atoi(f); (err != nil || n < 0 || n > 255) && !hexColor.MatchString(f) {
+			return lipgloss.AdaptiveColor{}, fmt.Errorf("invalid color %q, expected #rrggbb or 0-255", f)
+		}
+	}
+	return lipgloss.AdaptiveColor{Dark: fields[0], Light: fields[len(fields)-1]}, nil
+}
+
+// Setup sets the color profile, "none", "16", "256" or "truecolor", and
+// the background of the terminal, "dark" or "light". With "auto" the
+// profile comes from the terminal and the environment, which
+// turns the colors off for NO_COLOR, and the terminal is asked for its
+// background. Call it before the TUI starts, which owns the terminal.
+func Setup(colors, background string) error {
+	switch colors {
+	case "auto":
+		lipgloss.ColorProfile()
+	case "none":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	case "16":
+		lipgloss.SetColorProfile(termenv.ANSI)
+	case "256":
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	case "truecolor":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	default:
+		return fmt.Errorf("unknown color mode %q", colors)
+	}
+	switch background {
+	case "auto":
+		lipgloss.HasDarkBackground()
+	case "dark", "light":
+		lipgloss.SetHasDarkBackground(background == "dark")
+	default:
+		return fmt.Errorf("unknown background %q", background)
+	}
+	return nil
+}
+
+// sgrColor is a 256 or 24-bit color in an SGR escape
+var sgrColor = regexp.MustCompile(`\x1b\[([34])8;(?:5;(\d+)|2;(\d+);(\d+);(\d+))m`)
+
+// Degrade converts the colors of text, like the cover art, to the color
+// profile of the terminal, and removes them if it has none.
+func Degrade(text string) string {
+	p := lipgloss.ColorProfile()
+	switch p {
+	case termenv.TrueColor:
+		return text
+	case termenv.Ascii:
+		return stripSGR(text)
+	}
+	return sgrColor.ReplaceAllStringFunc(text, func(seq string) string {
+		m := sgrColor.FindStringSubmatch(seq)
+		var c termenv.Color
+		if m[2] != "" {
+			n, _ := strconv.Atoi(m[2])
+			c = termenv.ANSI256Color(n)
+		} else {
+			r, _ := strconv.Atoi(m[3])
+			g, _ := strconv.Atoi(m[4])
+			b, _ := strconv.Atoi(m[5])
+			c = p.Color(fmt.Sprintf("#%02x%02x%02x", r, g, b))
+		}
+		return termenv.CSI + p.Convert(c).Sequence(m[1] == "4") + "m"
+	})
+}
+
+var sgr = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func stripSGR(text string) string {
+	return sgr.ReplaceAllString(text, "")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// refreshAhead is how long before expiry tokens are refreshed
+const refreshAhead = 5 * time.Minute
+
+// tokenProvider hands out valid access tokens to the Go client and the
+// web player. Spotify's tokens live for an hour, the provider refreshes
+// them in the background before they expire.
+type tokenProvider struct {
+	mu  sync.Mutex
+	tok *oauth2.Token
+	// refresh exchanges the refresh token for a new token
+	refresh func(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error)
+}
+
+func newTokenProvider(tok *oauth2.Token, refresh func(context.Context, *oauth2.Token) (*oauth2.Token, error)) *tokenProvider {
+	return &tokenProvider{tok: tok, refresh: refresh}
+}
+
+// Token returns the current token, refreshing it first if it is about
+// to expire. It implements oauth2.TokenSource.
+func (p *tokenProvider) Token() (*oauth2.Token, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Until(p.tok.Expiry) > refreshAhead/2 {
+		return p.tok, nil
+	}
+	return p.refreshLocked(context.Background())
+}
+
+func (p *tokenProvider) refreshLocked(ctx context.Context) (*oauth2.Token, error) {
+	// an empty access token makes the refresh unconditional
+	old := *p.tok
+	old.AccessToken = ""
+	tok, err := p.refresh(ctx, &old)
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing token: %s", err)
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = p.tok.RefreshToken
+	}
+	p.tok = tok
+	logAuth.Info("refreshed token", "expires", tok.Expiry.Format(time.TimeOnly))
+	return tok, nil
+}
+
+// run refreshes the token ahead of its expiry until ctx is done
+func (p *tokenProvider) run(ctx context.Context) {
+	for {
+		p.mu.Lock()
+		wait := time.Until(p.tok.Expiry) - refreshAhead
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(max(wait, 0)):
+		}
+
+		p.mu.Lock()
+		_, err := p.refreshLocked(ctx)
+		p.mu.Unlock()
+		if err != nil {
+			logAuth.Warn("error refreshing token", "err", err)
+			// try again soon, Token still refreshes on demand
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(30 * time.Second):
+			}
+		}
+	}
+}
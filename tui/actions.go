@@ -0,0 +1,374 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/moritz-tiesler/spoli/radio"
+)
+
+// action is a named command that can be bound to keys and run from
+// the command palette.
+type action struct {
+	name string
+	// args describes the arguments, e.g. "<percent|+n|-n>"
+	args string
+	help string
+	// complete returns the candidates for the first argument
+	complete func(m model) []string
+	run      func(m model, args []string) (model, tea.Cmd, error)
+}
+
+var actions []action
+
+func init() {
+	actions = []action{
+		{name: "quit", help: "quit spoli", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			return m, tea.Quit, nil
+		}},
+		{name: "up", help: "move the cursor up", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			return m, nil, nil
+		}},
+		{name: "down", help: "move the cursor down", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			if m.cursor < len(m.choices)-1 {
+				m.cursor++
+			}
+			return m, nil, nil
+		}},
+		{name: "select", help: "run the command under the cursor", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			clear(m.selected)
+			m.selected[m.cursor] = struct{}{}
+			m, cmd := m.runCommand(m.choices[m.cursor])
+			return m, cmd, nil
+		}},
+		{
+			name: "toggle", args: "[play|pause]", help: "toggle or set playback",
+			complete: func(model) []string { return []string{"play", "pause"} },
+			run:      stateAction(event.TOGGLE_PLAY, "play", "pause"),
+		},
+		{name: "next", help: "skip to the next track", run: sendAction(event.NEXT)},
+		{name: "prev", help: "go back to the previous track", run: sendAction(event.PREV)},
+		{
+			name: "volume", args: "<percent|+n|-n>", help: "set or change the volume",
+			run: relativeAction(event.VOLUME, "volume"),
+		},
+		{
+			name: "seek", args: "<seconds|m:ss|+n|-n>", help: "seek to a position or by seconds",
+			run: relativeAction(event.SEEK, "position"),
+		},
+		{
+			name: "device", args: "<name>", help: "transfer playback to a device",
+			complete: func(m model) []string { return m.devices },
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				if len(args) == 0 {
+					return m, nil, fmt.Errorf("device needs a name")
+				}
+				m.send(event.New(event.DEVICE, map[any]any{"name": strings.Join(args, " ")}))
+				return m, nil, nil
+			},
+		},
+		{
+			name: "shuffle", args: "[on|off]", help: "toggle or set shuffle",
+			complete: func(model) []string { return []string{"on", "off"} },
+			run:      stateAction(event.SHUFFLE, "on", "off"),
+		},
+		{
+			name: "repeat", args: "[off|track|context]", help: "cycle or set the repeat mode",
+			complete: func(model) []string { return []string{"off", "track", "context"} },
+			run:      stateAction(event.REPEAT, "off", "track", "context"),
+		},
+		{name: "like", help: "like or unlike the current track or episode", run: saveAction(false)},
+		{name: "save-album", help: "save or remove the current album or show", run: saveAction(true)},
+		{
+			name: "radio", args: "[track|artist|genres] [energy=0-1] [tempo=bpm] [popularity=0-100] [size=n] [playlist[=name]]",
+			help:     "queue recommendations for the current track, or save them as a playlist",
+			complete: func(model) []string { return radio.Seeds },
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				o, err := radio.Parse(args)
+				if err != nil {
+					return m, nil, err
+				}
+				m.send(event.New(event.RADIO, map[any]any{"options": o}))
+				return m, nil, nil
+			},
+		},
+		{
+			name: "view", args: "<player|stats|playlists|podcasts>", help: "switch to a view",
+			complete: func(model) []string { return viewNames },
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				if len(args) == 0 {
+					return m, nil, fmt.Errorf("view needs a name")
+				}
+				for v, name := range viewNames {
+					if name == args[0] {
+						m, cmd := m.setView(view(v))
+						return m, cmd, nil
+					}
+				}
+				return m, nil, fmt.Errorf("unknown view %q", args[0])
+			},
+		},
+		{
+			name: "artist", help: "show the artist of the current track",
+			run: func(m model, _ []string) (model, tea.Cmd, error) {
+				t, err := m.currentTrack()
+				if err != nil {
+					return m, nil, err
+				}
+				if len(t.Artists) == 0 {
+					return m, nil, fmt.Errorf("the current track has no artist")
+				}
+				return m.openDetail(t.Artists[0].URI), nil, nil
+			},
+		},
+		{
+			name: "album", help: "show the album of the current track",
+			run: func(m model, _ []string) (model, tea.Cmd, error) {
+				t, err := m.currentTrack()
+				if err != nil {
+					return m, nil, err
+				}
+				return m.openDetail(t.Album.URI), nil, nil
+			},
+		},
+		{
+			name: "open", args: "<uri|link>", help: "show an artist or album",
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				if len(args) == 0 {
+					return m, nil, fmt.Errorf("open needs a Spotify URI or link")
+				}
+				l, err := link.Parse(args[0])
+				if err != nil {
+					return m, nil, err
+				}
+				if l.Kind != link.ARTIST && l.Kind != link.ALBUM {
+					return m, nil, fmt.Errorf("can't show a %s, only artists and albums", l.Kind)
+				}
+				return m.openDetail(l.URI()), nil, nil
+			},
+		},
+		{
+			name: "play", args: "<uri|link>", help: "play an album, playlist, artist, show, track or episode",
+			run: linkAction(false),
+		},
+		{
+			name: "queue", args: "<uri|link>", help: "add a track or episode to the queue",
+			run: linkAction(true),
+		},
+		{name: "next-view", help: "cycle through the views", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			m, cmd := m.setView((m.view + 1) % view(len(viewNames)))
+			return m, cmd, nil
+		}},
+		{name: "palette", help: "open the command palette", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			m.send(event.New(event.DEVICES, nil))
+			var cmd tea.Cmd
+			m.palette, cmd = m.palette.open(m)
+			return m, cmd, nil
+		}},
+		{name: "logs", help: "show or hide the log pane", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			if m.logs == nil {
+				return m, nil, fmt.Errorf("no logs to show")
+			}
+			m, cmd := m.setLayout(m.layout.toggle(logPane))
+			return m, cmd, nil
+		}},
+		{
+			name: "pane", args: "<now|art|queue|lyrics|log>", help: "show or hide a pane",
+			complete: func(model) []string { return paneNames },
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				if len(args) == 0 {
+					return m, nil, fmt.Errorf("pane needs a name")
+				}
+				if !slices.Contains(paneNames, args[0]) {
+					return m, nil, fmt.Errorf("unknown pane %q", args[0])
+				}
+				p := pane(args[0])
+				m, cmd := m.setLayout(m.layout.toggle(p))
+				if m.layout.has(p) && !m.visible(p) {
+					m.status = fmt.Sprintf("the %s pane shows when the terminal is wider", p)
+				}
+				return m, cmd, nil
+			},
+		},
+		{
+			name: "focus", args: "[pane]", help: "focus the next or the given pane",
+			complete: func(m model) []string {
+				var names []string
+				for _, p := range m.panes() {
+					names = append(names, string(p))
+				}
+				return names
+			},
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				ps := m.panes()
+				if len(args) == 0 {
+					m.focus = ps[(slices.Index(ps, m.focus)+1)%len(ps)]
+					return m, nil, nil
+				}
+				if !slices.Contains(ps, pane(args[0])) {
+					return m, nil, fmt.Errorf("the %s pane isn't shown", args[0])
+				}
+				m.focus = pane(args[0])
+				return m, nil, nil
+			},
+		},
+		{name: "zoom", help: "fill the screen with the focused pane, or go back", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			m.zoomed = !m.zoomed
+			m, cmd := m.refreshPanes()
+			return m, cmd, nil
+		}},
+		{
+			name: "resize", args: "<+n|-n>", help: "grow or shrink the focused pane",
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				if len(args) == 0 {
+					return m, nil, fmt.Errorf("resize needs a value")
+				}
+				n, relative, err := event.ParseRelative(args[0])
+				if err != nil || !relative {
+					return m, nil, fmt.Errorf("resize needs +n or -n")
+				}
+				w, h := m.bodySize()
+				l := m.layout
+				switch {
+				case slices.Contains(l.bottom, m.focus):
+					l.bottomHeight = max(min(l.bottomHeight+n, h/2), minBottomHeight)
+				case m.focus == libraryPane:
+					// the library takes the room the side leaves
+					n = -n
+					fallthrough
+				default:
+					l.sideWidth = max(min(l.sideWidth+n, w-minLibWidth), minSideWidth)
+				}
+				m, cmd := m.setLayout(l)
+				return m, cmd, nil
+			},
+		},
+		{
+			name: "layout", args: "<save|reset>", help: "save the pane layout, or go back to the default",
+			complete: func(model) []string { return []string{"save", "reset"} },
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				switch {
+				case len(args) == 0:
+					return m, nil, fmt.Errorf("layout needs save or reset")
+				case args[0] == "reset":
+					m, cmd := m.setLayout(DefaultLayout())
+					return m, cmd, nil
+				case args[0] != "save":
+					return m, nil, fmt.Errorf("layout needs save or reset")
+				case m.layoutFile == "":
+					return m, nil, fmt.Errorf("no layout file is configured")
+				}
+				if err := m.layout.Save(m.layoutFile); err != nil {
+					return m, nil, err
+				}
+				m.status = "layout saved to " + m.layoutFile
+				return m, nil, nil
+			},
+		},
+		{
+			name: "theme", args: "<name>", help: "switch to another color palette",
+			complete: func(m model) []string { return m.themes.Names() },
+			run: func(m model, args []string) (model, tea.Cmd, error) {
+				if len(args) == 0 {
+					return m, nil, fmt.Errorf("theme needs a name")
+				}
+				m, err := m.setTheme(args[0])
+				return m, nil, err
+			},
+		},
+		{name: "help", help: "show the key bindings", run: func(m model, _ []string) (model, tea.Cmd, error) {
+			m.showHelp = !m.showHelp
+			return m, nil, nil
+		}},
+	}
+}
+
+func findAction(name string) (action, bool) {
+	for _, a := range actions {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return action{}, false
+}
+
+func sendAction(e event.Kind) func(model, []string) (model, tea.Cmd, error) {
+	return func(m model, _ []string) (model, tea.Cmd, error) {
+		m.send(event.New(e, nil))
+		return m, nil, nil
+	}
+}
+
+// relativeAction sends e with an absolute or relative value, e.g. "30" or "+5"
+func relativeAction(e event.Kind, key string) func(model, []string) (model, tea.Cmd, error) {
+	return func(m model, args []string) (model, tea.Cmd, error) {
+		if len(args) == 0 {
+			return m, nil, fmt.Errorf("%s needs a value", e)
+		}
+		if _, _, err := event.ParseRelative(args[0]); err != nil {
+			return m, nil, err
+		}
+		m.send(event.New(e, map[any]any{key: args[0]}))
+		return m, nil, nil
+	}
+}
+
+// stateAction sends e with one of the given states, or without a state
+// to let the broker toggle or cycle it
+func stateAction(e event.Kind, states ...string) func(model, []string) (model, tea.Cmd, error) {
+	return func(m model, args []string) (model, tea.Cmd, error) {
+		state := ""
+		if len(args) > 0 {
+			state = args[0]
+			if !slices.Contains(states, state) {
+				return m, nil, fmt.Errorf("%s must be one of %s", e, strings.Join(states, ", "))
+			}
+		}
+		m.send(event.New(e, map[any]any{"state": state}))
+		return m, nil, nil
+	}
+}
+
+// linkAction plays or queues a Spotify URI or link
+func linkAction(queue bool) func(model, []string) (model, tea.Cmd, error) {
+	return func(m model, args []string) (model, tea.Cmd, error) {
+		if len(args) == 0 {
+			name := "play"
+			if queue {
+				name = "queue"
+			}
+			return m, nil, fmt.Errorf("%s needs a Spotify URI or link", name)
+		}
+		l, err := link.Parse(args[0])
+		if err != nil {
+			return m, nil, err
+		}
+		if _, err := l.Action(queue); err != nil {
+			return m, nil, err
+		}
+		m.send(event.New(event.LINK, map[any]any{"link": l, "queue": queue}))
+		return m, nil, nil
+	}
+}
+
+func saveAction(parent bool) func(model, []string) (model, tea.Cmd, error) {
+	return func(m model, _ []string) (model, tea.Cmd, error) {
+		si, ok := m.songInfo.(songInfo)
+		if !ok {
+			return m, nil, nil
+		}
+		e, ok := si.toggleSaved(parent)
+		if !ok {
+			return m, nil, fmt.Errorf("nothing to save")
+		}
+		m.send(e)
+		return m, nil, nil
+	}
+}
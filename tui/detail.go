@@ -0,0 +1,276 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moritz-tiesler/spoli/catalog"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/zmb3/spotify/v2"
+)
+
+// page is an artist or album on the navigation stack
+type page struct {
+	uri    spotify.URI
+	artist *catalog.Artist
+	album  *catalog.Album
+	err    error
+	cursor int // among the rows that can be selected
+}
+
+// row is a line of a page. Rows with a uri open it, rows with an event
+// send it, the others are headings.
+type row struct {
+	text string
+	uri  spotify.URI
+	play event.Event
+}
+
+func (r row) selectable() bool {
+	return r.uri != "" || r.play != nil
+}
+
+// detail shows the pages of artists and albums. Opened pages are kept on
+// a stack to go back and forward like in a browser. The broker answers
+// with a DETAIL event.
+type detail struct {
+	broker  Broker
+	results chan event.Event
+
+	stack []page
+	pos   int // of the current page, -1 if there is none
+	// height is the number of rows of a page
+	height int
+	st     *styles
+}
+
+func newDetail(b Broker, results chan event.Event, st *styles) detail {
+	return detail{broker: b, results: results, pos: -1, height: listHeight, st: st}
+}
+
+// open pushes the page of uri, dropping the pages ahead of the current one
+func (d detail) open(uri spotify.URI) detail {
+	d.stack = append(slices.Clip(d.stack[:d.pos+1]), page{uri: uri})
+	d.pos++
+	sendCommand(d.broker.Sink(), d.results, event.New(event.DETAIL_OPEN, map[any]any{"uri": uri}))
+	return d
+}
+
+func (d detail) current() (page, bool) {
+	if d.pos < 0 {
+		return page{}, false
+	}
+	return d.stack[d.pos], true
+}
+
+func (d detail) canBack() bool {
+	return d.pos > 0
+}
+
+func (d detail) updated(e event.Detail) detail {
+	data := e.Data()
+	uri, _ := data["uri"].(spotify.URI)
+	// the page may be anywhere on the stack by now
+	d.stack = slices.Clone(d.stack)
+	for i := range d.stack {
+		p := &d.stack[i]
+		if p.uri != uri || p.artist != nil || p.album != nil {
+			continue
+		}
+		p.artist, _ = data["artist"].(*catalog.Artist)
+		p.album, _ = data["album"].(*catalog.Album)
+		p.err, _ = data["error"].(error)
+	}
+	return d
+}
+
+// handles reports whether key is one of the view's own keys
+func (d detail) handles(key string) bool {
+	return slices.Contains([]string{"up", "k", "down", "j", "enter", " ", "esc", "backspace", "h", "l"}, key)
+}
+
+func (d detail) Update(msg tea.KeyMsg) (detail, tea.Cmd) {
+	p, ok := d.current()
+	if !ok {
+		return d, nil
+	}
+	rows := selectableRows(p.rows())
+	switch msg.String() {
+	case "up", "k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "j":
+		if p.cursor < len(rows)-1 {
+			p.cursor++
+		}
+	case "esc", "backspace", "h":
+		if d.canBack() {
+			d.pos--
+		}
+		return d, nil
+	case "l":
+		if d.pos < len(d.stack)-1 {
+			d.pos++
+		}
+		return d, nil
+	case "enter", " ":
+		if p.cursor >= len(rows) {
+			return d, nil
+		}
+		r := rows[p.cursor]
+		if r.play != nil {
+			sendCommand(d.broker.Sink(), d.results, r.play)
+			return d, nil
+		}
+		return d.open(r.uri), nil
+	}
+	d.stack = slices.Clone(d.stack)
+	d.stack[d.pos] = p
+	return d, nil
+}
+
+// selected is the index in rows of the row under the cursor, -1 if there
+// is none
+func (p page) selected(rows []row) int {
+	for i, n := 0, 0; i < len(rows); i++ {
+		if rows[i].selectable() {
+			if n == p.cursor {
+				return i
+			}
+			n++
+		}
+	}
+	return -1
+}
+
+// click moves the cursor to the row at line of the view. It reports
+// whether the row was under the cursor already.
+func (d detail) click(line int) (detail, bool) {
+	p, ok := d.current()
+	if !ok {
+		return d, false
+	}
+	rows := p.rows()
+	// below the title and the hint
+	line -= strings.Count(p.title(), "\n") + 3
+	start, end := window(len(rows), max(p.selected(rows), 0), d.height)
+	i := start + line
+	if line < 0 || i >= end || !rows[i].selectable() {
+		return d, false
+	}
+	n := len(selectableRows(rows[:i]))
+	if n == p.cursor {
+		return d, true
+	}
+	p.cursor = n
+	d.stack = slices.Clone(d.stack)
+	d.stack[d.pos] = p
+	return d, false
+}
+
+func selectableRows(rows []row) []row {
+	var sel []row
+	for _, r := range rows {
+		if r.selectable() {
+			sel = append(sel, r)
+		}
+	}
+	return sel
+}
+
+// rows lays out the page
+func (p page) rows() []row {
+	var rows []row
+	switch {
+	case p.artist != nil:
+		a := p.artist
+		var uris []spotify.URI
+		for _, t := range a.TopTracks {
+			uris = append(uris, t.URI)
+		}
+		if len(uris) > 0 {
+			rows = append(rows, row{text: "Top tracks"})
+		}
+		for _, t := range a.TopTracks {
+			rows = append(rows, row{
+				text: fmt.Sprintf("%2d. %s  %s", t.Number, t.Name, formatDuration(t.Duration)),
+				play: event.New(event.PLAY, map[any]any{"uris": uris, "item": t.URI}),
+			})
+		}
+		for _, group := range []struct {
+			name   string
+			albums []catalog.AlbumSummary
+		}{{"Albums", a.Albums}, {"Singles and EPs", a.Singles}, {"Compilations", a.Compilations}} {
+			if len(group.albums) == 0 {
+				continue
+			}
+			rows = append(rows, row{text: ""}, row{text: group.name})
+			for _, al := range group.albums {
+				rows = append(rows, row{text: fmt.Sprintf("%s  %s  (%d)", al.Year, al.Name, al.Tracks), uri: al.URI})
+			}
+		}
+		if len(a.Related) > 0 {
+			rows = append(rows, row{text: ""}, row{text: "Related artists"})
+			for _, r := range a.Related {
+				rows = append(rows, row{text: r.Name, uri: r.URI})
+			}
+		}
+	case p.album != nil:
+		a := p.album
+		for _, ar := range a.ArtistList {
+			rows = append(rows, row{text: "by " + ar.Name, uri: ar.URI})
+		}
+		rows = append(rows, row{text: ""})
+		for _, t := range a.Tracks {
+			rows = append(rows, row{
+				text: fmt.Sprintf("%2d. %s  %s", t.Number, t.Name, formatDuration(t.Duration)),
+				play: event.New(event.PLAY, map[any]any{"context": a.URI, "item": t.URI}),
+			})
+		}
+	}
+	return rows
+}
+
+func (p page) title() string {
+	switch {
+	case p.artist != nil:
+		a := p.artist
+		s := fmt.Sprintf("%s  (%d followers)", a.Name, a.Followers)
+		if len(a.Genres) > 0 {
+			s += "\n" + strings.Join(a.Genres, ", ")
+		}
+		return s
+	case p.album != nil:
+		a := p.album
+		return fmt.Sprintf("%s - %s  (%s, %d tracks, %s)", a.Artists, a.Name, a.Year, len(a.Tracks), formatDuration(a.Duration()))
+	case p.err != nil:
+		return "error: " + p.err.Error()
+	}
+	return "loading " + string(p.uri)
+}
+
+func (d detail) View() string {
+	p, ok := d.current()
+	if !ok {
+		return "Nothing opened yet, open the current artist or album, or a Spotify URI with :open\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", d.st.heading(p.title()))
+	b.WriteString(d.st.muted("enter open or play, h/esc back, l forward") + "\n\n")
+
+	rows := p.rows()
+	// the window follows the selected row
+	selected := p.selected(rows)
+	start, end := window(len(rows), max(selected, 0), d.height)
+	for i, r := range rows[start:end] {
+		if !r.selectable() {
+			b.WriteString(d.st.heading(r.text) + "\n")
+			continue
+		}
+		b.WriteString(d.st.row(start+i == selected, r.text) + "\n")
+	}
+	return b.String()
+}
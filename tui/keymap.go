@@ -0,0 +1,176 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Keymap maps key sequences to commands. A sequence is one or more
+// space separated keys, e.g. "ctrl+x n", a command is an action name
+// followed by its arguments, e.g. "volume +5".
+type Keymap map[string]string
+
+func DefaultKeymap() Keymap {
+	return Keymap{
+		"ctrl+c":    "quit",
+		"q":         "quit",
+		"up":        "up",
+		"k":         "up",
+		"down":      "down",
+		"j":         "down",
+		"enter":     "select",
+		" ":         "select",
+		"p":         "toggle",
+		"n":         "next",
+		"b":         "prev",
+		"+":         "volume +5",
+		"-":         "volume -5",
+		"right":     "seek +15",
+		"left":      "seek -15",
+		"]":         "seek +30",
+		"[":         "seek -30",
+		"s":         "shuffle",
+		"r":         "repeat",
+		"l":         "like",
+		"L":         "save-album",
+		"R":         "radio",
+		"tab":       "next-view",
+		"g p":       "view player",
+		"g s":       "view stats",
+		"g l":       "view playlists",
+		"g c":       "view podcasts",
+		"g a":       "artist",
+		"g b":       "album",
+		"g d":       "logs",
+		"w w":       "focus",
+		"shift+tab": "focus",
+		"w z":       "zoom",
+		"w n":       "pane now",
+		"w a":       "pane art",
+		"w q":       "pane queue",
+		"w y":       "pane lyrics",
+		"w >":       "resize +4",
+		"w <":       "resize -4",
+		"w s":       "layout save",
+		":":         "palette",
+		"?":         "help",
+	}
+}
+
+// LoadKeymap reads the keymap file at path on top of the default keymap.
+// A missing file is not an error. Each line of the file binds a key
+// sequence to a command:
+//
+//	# comment
+//	ctrl+x n = next
+//	+ = volume +10
+//	q = none
+//
+// Binding a sequence to "none" removes its default binding.
+func LoadKeymap(path string) (Keymap, error) {
+	km := DefaultKeymap()
+	if path == "" {
+		return km, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return km, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening keymap: %s", err)
+	}
+	defer f.Close()
+	if err := km.read(f); err != nil {
+		return nil, fmt.Errorf("error reading keymap %s: %s", path, err)
+	}
+	return km, nil
+}
+
+func (km Keymap) read(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// the key itself may be "=", so split at the last one
+		i := strings.LastIndex(line, "=")
+		if i <= 0 {
+			return fmt.Errorf("line %d: expected 'keys = command'", n)
+		}
+		keys := normalizeKeys(line[:i])
+		cmd := strings.TrimSpace(line[i+1:])
+		if keys == "" || cmd == "" {
+			return fmt.Errorf("line %d: expected 'keys = command'", n)
+		}
+		if cmd == "none" {
+			delete(km, keys)
+			continue
+		}
+		name, _, _ := strings.Cut(cmd, " ")
+		if _, ok := findAction(name); !ok {
+			return fmt.Errorf("line %d: unknown action %q", n, name)
+		}
+		km[keys] = cmd
+	}
+	return sc.Err()
+}
+
+// normalizeKeys collapses whitespace between keys. "space" names the
+// space bar, which can not be written literally.
+func normalizeKeys(s string) string {
+	keys := strings.Fields(s)
+	for i, k := range keys {
+		if k == "space" {
+			keys[i] = " "
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+// lookup reports the command bound to seq and whether seq is the
+// prefix of a longer binding.
+func (km Keymap) lookup(seq []string) (cmd string, prefix bool) {
+	s := strings.Join(seq, " ")
+	cmd = km[s]
+	for k := range km {
+		if strings.HasPrefix(k, s+" ") {
+			return cmd, true
+		}
+	}
+	return cmd, false
+}
+
+// Help lists all bindings sorted by action.
+func (km Keymap) Help() string {
+	byCmd := map[string][]string{}
+	for k, cmd := range km {
+		if k == " " {
+			k = "space"
+		}
+		byCmd[cmd] = append(byCmd[cmd], k)
+	}
+	cmds := make([]string, 0, len(byCmd))
+	for cmd := range byCmd {
+		cmds = append(cmds, cmd)
+	}
+	slices.Sort(cmds)
+
+	var b strings.Builder
+	for _, cmd := range cmds {
+		keys := byCmd[cmd]
+		slices.Sort(keys)
+		help := ""
+		name, _, _ := strings.Cut(cmd, " ")
+		if a, ok := findAction(name); ok {
+			help = a.help
+		}
+		fmt.Fprintf(&b, "%-16s %-20s %s\n", strings.Join(keys, ", "), cmd, help)
+	}
+	return b.String()
+}
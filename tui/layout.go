@@ -0,0 +1,494 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/zmb3/spotify/v2"
+)
+
+// pane is a part of the screen
+type pane string
+
+const (
+	// libraryPane shows the current view, it is always there
+	libraryPane pane = "library"
+	nowPane     pane = "now"
+	artPane     pane = "art"
+	queuePane   pane = "queue"
+	lyricsPane  pane = "lyrics"
+	logPane     pane = "log"
+)
+
+// paneNames are the panes that can be shown and hidden
+var paneNames = []string{string(nowPane), string(artPane), string(queuePane), string(lyricsPane), string(logPane)}
+
+const (
+	// terminals narrower than compactWidth get a single column, with the
+	// now playing pane on top
+	compactWidth    = 80
+	minSideWidth    = 20
+	minLibWidth     = 30
+	minBottomHeight = 3
+	// nowHeight fits the now playing pane's title and lines
+	nowHeight = songLines + 3
+)
+
+// Layout arranges the panes: the library on the left, the side panes
+// stacked on the right and the bottom panes next to each other below
+// both.
+type Layout struct {
+	side         []pane
+	bottom       []pane
+	sideWidth    int
+	bottomHeight int
+}
+
+func DefaultLayout() Layout {
+	return Layout{side: []pane{nowPane, artPane}, sideWidth: 44, bottomHeight: 10}
+}
+
+// LoadLayout reads the layout file at path. A missing file is not an
+// error, it gives the default layout. The file looks like
+//
+//	# side panes from top to bottom
+//	side = now art queue
+//	bottom = log
+//	side_width = 44
+//	bottom_height = 10
+func LoadLayout(path string) (Layout, error) {
+	l := DefaultLayout()
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("error opening layout: %s", err)
+	}
+	defer f.Close()
+	if err := l.read(f); err != nil {
+		return l, fmt.Errorf("error reading layout %s: %s", path, err)
+	}
+	return l, nil
+}
+
+func (l *Layout) read(r io.Reader) error {
+	seen := map[pane]bool{}
+	panes := func(v string) ([]pane, error) {
+		var ps []pane
+		for _, name := range strings.Fields(v) {
+			if !slices.Contains(paneNames, name) {
+				return nil, fmt.Errorf("unknown pane %q", name)
+			}
+			if seen[pane(name)] {
+				return nil, fmt.Errorf("pane %q is placed twice", name)
+			}
+			seen[pane(name)] = true
+			ps = append(ps, pane(name))
+		}
+		return ps, nil
+	}
+	// sizes too small are raised to the smallest, too large ones are
+	// limited to the terminal, see geometry
+	size := func(v string, least int) (int, error) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, err
+		}
+		return max(n, least), nil
+	}
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected 'key = value'", n)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		var err error
+		switch k {
+		case "side":
+			l.side, err = panes(v)
+		case "bottom":
+			l.bottom, err = panes(v)
+		case "side_width":
+			l.sideWidth, err = size(v, minSideWidth)
+		case "bottom_height":
+			l.bottomHeight, err = size(v, minBottomHeight)
+		default:
+			err = fmt.Errorf("unknown key %q", k)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// Save writes the layout to path
+func (l Layout) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error saving layout: %s", err)
+	}
+	names := func(ps []pane) string {
+		s := make([]string, 0, len(ps))
+		for _, p := range ps {
+			s = append(s, string(p))
+		}
+		return strings.Join(s, " ")
+	}
+	data := fmt.Sprintf("# written by spoli, see the layout action\nside = %s\nbottom = %s\nside_width = %d\nbottom_height = %d\n",
+		names(l.side), names(l.bottom), l.sideWidth, l.bottomHeight)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		return fmt.Errorf("error saving layout: %s", err)
+	}
+	return nil
+}
+
+func (l Layout) has(p pane) bool {
+	return slices.Contains(l.side, p) || slices.Contains(l.bottom, p)
+}
+
+// toggle hides p or shows it, the log at the bottom and the others at the
+// side
+func (l Layout) toggle(p pane) Layout {
+	if l.has(p) {
+		l.side = slices.DeleteFunc(slices.Clone(l.side), func(q pane) bool { return q == p })
+		l.bottom = slices.DeleteFunc(slices.Clone(l.bottom), func(q pane) bool { return q == p })
+		return l
+	}
+	if p == logPane {
+		l.bottom = append(slices.Clip(l.bottom), p)
+	} else {
+		l.side = append(slices.Clip(l.side), p)
+	}
+	return l
+}
+
+// rect is the place of a pane on the screen
+type rect struct {
+	x, y, w, h int
+}
+
+func (r rect) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.w && y >= r.y && y < r.y+r.h
+}
+
+// size is the terminal size, before the first resize a common default
+func (m model) size() (int, int) {
+	if m.width == 0 || m.height == 0 {
+		return 80, 24
+	}
+	return m.width, m.height
+}
+
+func (m model) compact() bool {
+	w, _ := m.size()
+	return w < compactWidth
+}
+
+// geometry places the visible panes in a body of width by height cells
+func (m model) geometry(width, height int) map[pane]rect {
+	g := map[pane]rect{}
+	if m.zoomed {
+		g[m.focus] = rect{0, 0, width, height}
+		return g
+	}
+	if len(m.layout.bottom) > 0 {
+		h := min(m.layout.bottomHeight, height/2)
+		height -= h
+		split(g, m.layout.bottom, rect{0, height, width, h}, true)
+	}
+	if m.compact() {
+		h := min(nowHeight, height/2)
+		g[nowPane] = rect{0, 0, width, h}
+		g[libraryPane] = rect{0, h, width, height - h}
+		return g
+	}
+	if len(m.layout.side) == 0 {
+		g[libraryPane] = rect{0, 0, width, height}
+		return g
+	}
+	sw := max(min(m.layout.sideWidth, width-minLibWidth), minSideWidth)
+	g[libraryPane] = rect{0, 0, width - sw, height}
+	side := rect{width - sw, 0, sw, height}
+	rest := m.layout.side
+	// the now playing pane needs no more than its lines, it goes on top
+	// if it comes first and to the bottom otherwise
+	if i := slices.Index(rest, nowPane); i >= 0 && len(rest) > 1 {
+		rest = slices.Delete(slices.Clone(rest), i, i+1)
+		h := min(nowHeight, height/2)
+		y := 0
+		if i > 0 {
+			y = height - h
+		} else {
+			side.y = h
+		}
+		side.h -= h
+		g[nowPane] = rect{side.x, y, sw, h}
+	}
+	split(g, rest, side, false)
+	return g
+}
+
+// split divides r evenly among ps, side by side or stacked
+func split(g map[pane]rect, ps []pane, r rect, sideBySide bool) {
+	for i, p := range ps {
+		if sideBySide {
+			x := r.x + r.w*i/len(ps)
+			g[p] = rect{x, r.y, r.x + r.w*(i+1)/len(ps) - x, r.h}
+		} else {
+			y := r.y + r.h*i/len(ps)
+			g[p] = rect{r.x, y, r.w, r.y + r.h*(i+1)/len(ps) - y}
+		}
+	}
+}
+
+// panes are the visible panes in focus order
+func (m model) panes() []pane {
+	g := m.geometry(m.bodySize())
+	ps := make([]pane, 0, len(g))
+	for p := range g {
+		ps = append(ps, p)
+	}
+	// left to right, top to bottom
+	sort.Slice(ps, func(i, j int) bool {
+		a, b := g[ps[i]], g[ps[j]]
+		if a.y+a.h <= b.y || b.y+b.h <= a.y {
+			return a.y < b.y
+		}
+		return a.x < b.x
+	})
+	return ps
+}
+
+func (m model) visible(p pane) bool {
+	_, ok := m.geometry(m.bodySize())[p]
+	return ok
+}
+
+// bodySize is the room for the panes, the footer takes the rest
+func (m model) bodySize() (int, int) {
+	w, h := m.size()
+	return w, max(h-lipgloss.Height(m.footer()), 3)
+}
+
+// render draws the panes of g, which tile the body
+func (m model) render(g map[pane]rect, height int) string {
+	ps := make([]pane, 0, len(g))
+	for p := range g {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return g[ps[i]].x < g[ps[j]].x })
+	lines := make([]string, height)
+	for _, p := range ps {
+		r := g[p]
+		for i, line := range strings.Split(m.box(p, r.w, r.h), "\n") {
+			if r.y+i < height {
+				lines[r.y+i] += line
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// box draws pane p with its border and title in w by h cells
+func (m model) box(p pane, w, h int) string {
+	if w < 3 || h < 3 {
+		return strings.Repeat(strings.Repeat(" ", max(w, 0))+"\n", max(h-1, 0)) + strings.Repeat(" ", max(w, 0))
+	}
+	inner, rows := w-2, h-2
+	lines := []string{ansi.Truncate(m.paneTitle(p), inner, "…")}
+	for _, line := range strings.Split(strings.TrimRight(m.paneContent(p, inner, rows-1), "\n"), "\n") {
+		if len(lines) == rows {
+			break
+		}
+		lines = append(lines, ansi.Truncate(line, inner, "…"))
+	}
+	return m.st.border(p == m.focus).Width(inner).Height(rows).Render(strings.Join(lines, "\n"))
+}
+
+func (m model) paneTitle(p pane) string {
+	title := func(s string) string {
+		if p == m.focus {
+			return m.st.accented(m.st.heading(s))
+		}
+		return m.st.heading(s)
+	}
+	switch p {
+	case libraryPane:
+		if m.view == detailView {
+			return title("detail")
+		}
+		tabs := make([]string, len(viewNames))
+		for i, name := range viewNames {
+			tabs[i] = m.st.muted(name)
+			if view(i) == m.view {
+				tabs[i] = title(name)
+			}
+		}
+		return strings.Join(tabs, "  ")
+	case nowPane:
+		return title("now playing")
+	case logPane:
+		return title("log") + m.st.muted(" pgup/pgdown to scroll")
+	}
+	return title(string(p))
+}
+
+// paneContent is what p shows in w by h cells
+func (m model) paneContent(p pane, w, h int) string {
+	switch p {
+	case libraryPane:
+		return m.libraryView(w)
+	case nowPane:
+		si, _ := m.songInfo.(songInfo)
+		return si.render(w)
+	case artPane:
+		return m.art.View()
+	case queuePane:
+		return m.queue.View(h)
+	case lyricsPane:
+		si, _ := m.songInfo.(songInfo)
+		return m.lyrics.View(m.st, si.progress(), h)
+	case logPane:
+		vp := m.viewport
+		vp.Width, vp.Height = w, h
+		return vp.View()
+	}
+	return ""
+}
+
+// innerSize is the room for the content of p, without border and title
+func (m model) innerSize(p pane) (int, int, bool) {
+	r, ok := m.geometry(m.bodySize())[p]
+	return max(r.w-2, 0), max(r.h-3, 0), ok
+}
+
+// setLayout switches to layout l
+func (m model) setLayout(l Layout) (model, tea.Cmd) {
+	m.layout = l
+	m.zoomed = false
+	return m.refreshPanes()
+}
+
+// refreshPanes fits the panes to the layout and the terminal, and loads
+// what the visible panes show for the current track
+func (m model) refreshPanes() (model, tea.Cmd) {
+	if !m.visible(m.focus) {
+		m.focus, m.zoomed = libraryPane, false
+	}
+	var cmds []tea.Cmd
+	if _, h, ok := m.innerSize(libraryPane); ok {
+		// the lists leave room for their header and status
+		rows := max(h-5, 3)
+		m.playlists.height, m.podcasts.height, m.detail.height = rows, rows, rows
+	}
+	if w, h, ok := m.innerSize(logPane); ok {
+		m.viewport.Width, m.viewport.Height = w, h
+		if !m.logsOn {
+			m.logsOn = true
+			m.logSeq++
+			m = m.refreshLogs()
+			m.viewport.GotoBottom()
+			cmds = append(cmds, m.logTick())
+		}
+	} else {
+		m.logsOn = false
+	}
+
+	t := m.track()
+	var id spotify.ID
+	if t != nil {
+		id = t.ID
+	}
+	if w, h, ok := m.innerSize(artPane); ok {
+		var cmd tea.Cmd
+		m.art, cmd = m.art.load(m.draw, coverURL(t), w, h)
+		cmds = append(cmds, cmd)
+	}
+	if m.visible(queuePane) && id != m.queue.id {
+		m.queue = queue{id: id}
+		if id != "" {
+			m.send(event.New(event.QUEUE, nil))
+		}
+	}
+	if m.visible(lyricsPane) && id != m.lyrics.id {
+		m.lyrics = lyricsView{id: id}
+		if id != "" {
+			m.send(event.New(event.LYRICS, nil))
+		}
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// coverURL is the url of the smallest cover of t that still looks good
+// as characters, empty if t has none
+func coverURL(t *spotify.FullTrack) string {
+	if t == nil || len(t.Album.Images) == 0 {
+		return ""
+	}
+	// the largest cover comes first
+	url := t.Album.Images[0].URL
+	for _, img := range t.Album.Images {
+		if img.Width >= 300 {
+			url = img.URL
+		}
+	}
+	return url
+}
+
+// scrollKeys are the keys that scroll the focused pane, by lines
+var scrollKeys = map[string]int{"up": -1, "k": -1, "down": 1, "j": 1, "pgup": -10, "pgdown": 10}
+
+// scrollPane passes the scroll keys to the focused pane, esc gives the
+// focus back to the library. pgup and pgdown scroll the log pane if the
+// focused pane doesn't scroll.
+func (m model) scrollPane(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	key := msg.String()
+	if key == "esc" && m.focus != libraryPane {
+		m.focus, m.zoomed = libraryPane, false
+		return m, nil, true
+	}
+	by, ok := scrollKeys[key]
+	if !ok {
+		return m, nil, false
+	}
+	p := m.focus
+	if p != queuePane && p != lyricsPane && p != logPane {
+		if !m.logsOn || (key != "pgup" && key != "pgdown") {
+			return m, nil, false
+		}
+		p = logPane
+	}
+	switch p {
+	case queuePane:
+		m.queue = m.queue.scroll(by)
+	case lyricsPane:
+		m.lyrics = m.lyrics.scroll(by)
+	case logPane:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd, true
+	}
+	return m, nil, true
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/moritz-tiesler/spoli/event"
+)
+
+// wheelLines is how far the wheel scrolls the queue, lyrics and log
+const wheelLines = 3
+
+// mouse handles clicks, drags and the wheel. A click focuses the pane
+// under the mouse, a click on the selected row of a list activates it.
+func (m model) mouse(msg tea.MouseMsg) (model, tea.Cmd) {
+	if m.showHelp || m.palette.active {
+		return m, nil
+	}
+	if si, _ := m.songInfo.(songInfo); si.seekTo != nil {
+		return m.dragSeek(msg)
+	}
+	p, r, ok := m.paneAt(msg.X, msg.Y)
+	if !ok {
+		return m, nil
+	}
+	// relative to the content, below the border and the title
+	x, y, width := msg.X-r.x-1, msg.Y-r.y-2, r.w-2
+
+	if msg.Action != tea.MouseActionPress {
+		return m, nil
+	}
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		return m.wheel(p, y, -1)
+	case tea.MouseButtonWheelDown:
+		return m.wheel(p, y, 1)
+	case tea.MouseButtonLeft:
+	default:
+		return m, nil
+	}
+
+	m.focus = p
+	switch p {
+	case libraryPane:
+		if y == -1 {
+			return m.clickTab(x)
+		}
+		return m.clickLibrary(x, y, width)
+	case nowPane:
+		return m.clickSong(x, y, width, r)
+	}
+	return m, nil
+}
+
+// paneAt returns the pane at x, y of the screen
+func (m model) paneAt(x, y int) (pane, rect, bool) {
+	for p, r := range m.geometry(m.bodySize()) {
+		if r.contains(x, y) {
+			return p, r, true
+		}
+	}
+	return "", rect{}, false
+}
+
+// wheel scrolls pane p by lines, over the volume bar it turns the volume
+// up and down
+func (m model) wheel(p pane, y, by int) (model, tea.Cmd) {
+	onSong := p == nowPane || (p == libraryPane && m.view == playerView && !m.visible(nowPane))
+	if onSong && y == volumeLine {
+		step := "+5"
+		if by > 0 {
+			step = "-5"
+		}
+		m.send(event.New(event.VOLUME, map[any]any{"volume": step}))
+		return m, nil
+	}
+	switch p {
+	case libraryPane:
+		key := tea.KeyMsg{Type: tea.KeyDown}
+		if by < 0 {
+			key = tea.KeyMsg{Type: tea.KeyUp}
+		}
+		return m.viewKey(key)
+	case queuePane:
+		m.queue = m.queue.scroll(by * wheelLines)
+	case lyricsPane:
+		m.lyrics = m.lyrics.scroll(by * wheelLines)
+	case logPane:
+		if by < 0 {
+			m.viewport.ScrollUp(wheelLines)
+		} else {
+			m.viewport.ScrollDown(wheelLines)
+		}
+	}
+	return m, nil
+}
+
+// clickTab switches to the view of the tab at column x of the library
+// pane's title
+func (m model) clickTab(x int) (model, tea.Cmd) {
+	if m.view == detailView {
+		return m, nil
+	}
+	// the tabs are joined by two spaces, see paneTitle
+	start := 0
+	for i, name := range viewNames {
+		end := start + len(name)
+		if x >= start && x < end {
+			return m.setView(view(i))
+		}
+		start = end + 2
+	}
+	return m, nil
+}
+
+// clickLibrary selects the row at line y of the current view, or runs it
+// if it was selected already
+func (m model) clickLibrary(x, y, width int) (model, tea.Cmd) {
+	var again bool
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	switch m.view {
+	case playerView:
+		if !m.visible(nowPane) {
+			// the song is shown above the choices
+			si, _ := m.songInfo.(songInfo)
+			lines := lipgloss.Height(si.render(width))
+			if y < lines {
+				r, _ := m.geometry(m.bodySize())[libraryPane]
+				return m.clickSong(x, y, width, r)
+			}
+			y -= lines + 1
+		}
+		if y < 0 || y >= len(m.choices) {
+			return m, nil
+		}
+		if m.cursor == y {
+			return m.runCommand("select")
+		}
+		m.cursor = y
+	case playlistsView:
+		if m.playlists.capturesInput() {
+			return m, nil
+		}
+		m.playlists, again = m.playlists.click(y)
+		if again && m.playlists.handles("enter") {
+			var cmd tea.Cmd
+			m.playlists, cmd = m.playlists.Update(enter)
+			return m, cmd
+		}
+	case podcastsView:
+		m.podcasts, again = m.podcasts.click(y)
+		if again {
+			var cmd tea.Cmd
+			m.podcasts, cmd = m.podcasts.Update(enter)
+			return m, cmd
+		}
+	case detailView:
+		m.detail, again = m.detail.click(y)
+		if again {
+			var cmd tea.Cmd
+			m.detail, cmd = m.detail.Update(enter)
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+// clickSong seeks on a click on the progress bar and starts dragging it,
+// and sets the volume on a click on the volume bar. r is the pane the
+// song is shown in.
+func (m model) clickSong(x, y, width int, r rect) (model, tea.Cmd) {
+	si, _ := m.songInfo.(songInfo)
+	switch y {
+	case progressLine:
+		if d, ok := si.seekAt(x, width); ok {
+			si.seekTo = &d
+			m.songInfo = si
+			m.seekRect = r
+		}
+	case volumeLine:
+		if v, ok := si.volumeAt(x); ok {
+			m.send(event.New(event.VOLUME, map[any]any{"volume": strconv.Itoa(v)}))
+		}
+	}
+	return m, nil
+}
+
+// dragSeek moves the dragged progress bar, and seeks to where it is let
+// go
+func (m model) dragSeek(msg tea.MouseMsg) (model, tea.Cmd) {
+	si, _ := m.songInfo.(songInfo)
+	if si.state == nil || si.state.Item == nil {
+		// the song ended
+		si.seekTo = nil
+		m.songInfo = si
+		return m, nil
+	}
+	r := m.seekRect
+	width := r.w - 2
+	// the mouse may leave the bar while dragging
+	x := min(max(msg.X-r.x-1, 0), si.barWidth(width)-1)
+	if d, ok := si.seekAt(x, width); ok {
+		si.seekTo = &d
+	}
+	// a press ends the drag too, if the release was missed
+	if msg.Action != tea.MouseActionMotion {
+		seconds := int(si.seekTo.Round(time.Second).Seconds())
+		m.send(event.New(event.SEEK, map[any]any{"position": strconv.Itoa(seconds)}))
+		si.seekTo = nil
+	}
+	m.songInfo = si
+	return m, nil
+}
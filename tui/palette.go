@@ -0,0 +1,172 @@
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const maxCompletions = 8
+
+// palette is the ':' command line. It completes action names and
+// their arguments with fuzzy matching.
+type palette struct {
+	input  textinput.Model
+	active bool
+
+	completions []completion
+	cursor      int
+	st          *styles
+}
+
+type completion struct {
+	// text replaces the input when the completion is accepted
+	text string
+	// desc is shown next to it
+	desc  string
+	score int
+}
+
+func newPalette(st *styles) palette {
+	ti := textinput.New()
+	ti.Prompt = ":"
+	ti.CharLimit = 200
+	return palette{input: ti, st: st}
+}
+
+func (p palette) open(m model) (palette, tea.Cmd) {
+	p.active = true
+	p.input.SetValue("")
+	p = p.complete(m)
+	return p, p.input.Focus()
+}
+
+func (p palette) close() palette {
+	p.active = false
+	p.input.Blur()
+	p.completions = nil
+	return p
+}
+
+// complete recomputes the completions for the current input
+func (p palette) complete(m model) palette {
+	p.cursor = 0
+	p.completions = nil
+	line := p.input.Value()
+	name, arg, hasArg := strings.Cut(strings.TrimLeft(line, " "), " ")
+
+	if !hasArg {
+		for _, a := range actions {
+			if score, ok := fuzzy(name, a.name); ok {
+				p.completions = append(p.completions, completion{
+					text:  a.name + " ",
+					desc:  strings.TrimSpace(a.args + "  " + a.help),
+					score: score,
+				})
+			}
+		}
+	} else if a, ok := findAction(name); ok && a.complete != nil {
+		arg = strings.TrimLeft(arg, " ")
+		for _, c := range a.complete(m) {
+			if score, ok := fuzzy(arg, c); ok {
+				p.completions = append(p.completions, completion{text: a.name + " " + c, score: score})
+			}
+		}
+	}
+
+	slices.SortStableFunc(p.completions, func(a, b completion) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	if len(p.completions) > maxCompletions {
+		p.completions = p.completions[:maxCompletions]
+	}
+	return p
+}
+
+// Update handles keys while the palette is open. It returns the command
+// line to run once the user presses enter.
+func (p palette) Update(m model, msg tea.KeyMsg) (palette, string, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		return p.close(), "", nil
+	case "enter":
+		// run the selected completion, free form arguments like
+		// volumes have none and are run as typed
+		line := strings.TrimSpace(p.input.Value())
+		if len(p.completions) > 0 {
+			line = strings.TrimSpace(p.completions[p.cursor].text)
+		}
+		return p.close(), line, nil
+	case "tab":
+		if len(p.completions) > 0 {
+			p.input.SetValue(p.completions[p.cursor].text)
+			p.input.CursorEnd()
+			p = p.complete(m)
+		}
+		return p, "", nil
+	case "up", "ctrl+p":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+		return p, "", nil
+	case "down", "ctrl+n":
+		if p.cursor < len(p.completions)-1 {
+			p.cursor++
+		}
+		return p, "", nil
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return p.complete(m), "", cmd
+}
+
+func (p palette) View() string {
+	var b strings.Builder
+	b.WriteString(p.input.View())
+	b.WriteString("\n")
+	for i, c := range p.completions {
+		row := p.st.row(i == p.cursor, fmt.Sprintf("%-24s", c.text))
+		fmt.Fprintf(&b, "%s %s\n", row, p.st.muted(c.desc))
+	}
+	return b.String()
+}
+
+// fuzzy reports whether the characters of pattern appear in s in order,
+// ignoring case. Matches at the start of s and of words and runs of
+// consecutive characters score higher.
+func fuzzy(pattern, s string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p := []rune(strings.ToLower(pattern))
+	r := []rune(strings.ToLower(s))
+	score, pi, last := 0, 0, -2
+	for i := 0; i < len(r) && pi < len(p); i++ {
+		if r[i] != p[pi] {
+			continue
+		}
+		switch {
+		case i == 0:
+			score += 10
+		case !unicode.IsLetter(r[i-1]):
+			score += 6
+		case last == i-1:
+			score += 4
+		default:
+			score++
+		}
+		last = i
+		pi++
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+	// prefer shorter candidates among equal matches
+	return score*100 - len(r), true
+}
@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/lyrics"
+	"github.com/moritz-tiesler/spoli/theme"
+	"github.com/zmb3/spotify/v2"
+)
+
+// art is the cover of the current track in the art pane
+type art struct {
+	// key is the url and size of the cover that is drawn or requested
+	key  string
+	text string
+	err  error
+}
+
+// artMsg carries the drawn cover for key
+type artMsg struct {
+	key  string
+	text string
+	err  error
+}
+
+func (a art) View() string {
+	switch {
+	case a.err != nil:
+		return "no cover: " + a.err.Error()
+	case a.key == "":
+		return "no cover"
+	}
+	return a.text
+}
+
+// load draws the cover at url in w by h cells, unless it is drawn already
+func (a art) load(draw func(url string, w, h int) (string, error), url string, w, h int) (art, tea.Cmd) {
+	if url == "" || draw == nil {
+		return art{}, nil
+	}
+	key := fmt.Sprintf("%s %dx%d", url, w, h)
+	if key == a.key {
+		return a, nil
+	}
+	a.key = key
+	return a, func() tea.Msg {
+		text, err := draw(url, w, h)
+		return artMsg{key: key, text: text, err: err}
+	}
+}
+
+func (a art) loaded(msg artMsg) art {
+	if msg.key == a.key {
+		// the cover is colored for any terminal
+		a.text, a.err = theme.Degrade(msg.text), msg.err
+	}
+	return a
+}
+
+// queue is the list of the next tracks in the queue pane
+type queue struct {
+	// id is the track the queue was requested for
+	id     spotify.ID
+	items  []string
+	err    error
+	offset int
+}
+
+func (q queue) updated(e event.Event) queue {
+	if id, _ := e.Data()["id"].(spotify.ID); id != q.id {
+		return q
+	}
+	q.items, _ = e.Data()["items"].([]string)
+	q.err, _ = e.Data()["error"].(error)
+	q.offset = 0
+	return q
+}
+
+func (q queue) scroll(by int) queue {
+	q.offset = max(min(q.offset+by, len(q.items)-1), 0)
+	return q
+}
+
+func (q queue) View(h int) string {
+	switch {
+	case q.err != nil:
+		return q.err.Error()
+	case q.id == "":
+		return "nothing playing"
+	case q.items == nil:
+		return "loading"
+	case len(q.items) == 0:
+		return "the queue is empty"
+	}
+	var b strings.Builder
+	for i, it := range q.items[q.offset:min(q.offset+h, len(q.items))] {
+		fmt.Fprintf(&b, "%2d. %s\n", q.offset+i+1, it)
+	}
+	return b.String()
+}
+
+// lyricsView shows the lyrics of the current track, synced lyrics follow
+// the progress
+type lyricsView struct {
+	id     spotify.ID
+	lyrics *lyrics.Lyrics
+	err    error
+	// offset scrolls lyrics that aren't synced
+	offset int
+}
+
+func (l lyricsView) updated(e event.Event) lyricsView {
+	if id, _ := e.Data()["id"].(spotify.ID); id != l.id {
+		return l
+	}
+	l.lyrics, _ = e.Data()["lyrics"].(*lyrics.Lyrics)
+	l.err, _ = e.Data()["error"].(error)
+	l.offset = 0
+	return l
+}
+
+func (l lyricsView) scroll(by int) lyricsView {
+	if l.lyrics != nil {
+		l.offset = max(min(l.offset+by, len(l.lyrics.Lines)-1), 0)
+	}
+	return l
+}
+
+func (l lyricsView) View(st *styles, progress time.Duration, h int) string {
+	switch {
+	case errors.Is(l.err, lyrics.ErrNotFound):
+		return "no lyrics found"
+	case l.err != nil:
+		return l.err.Error()
+	case l.id == "":
+		return "nothing playing"
+	case l.lyrics == nil:
+		return "loading"
+	case l.lyrics.Instrumental:
+		return "instrumental"
+	}
+	lines := l.lyrics.Lines
+	current := l.lyrics.Current(progress)
+	start := l.offset
+	if l.lyrics.Synced {
+		// the current line stays in the middle
+		start = max(min(current-h/2, len(lines)-h), 0)
+	}
+	var b strings.Builder
+	for i, line := range lines[start:min(start+h, len(lines))] {
+		text := line.Text
+		if start+i == current {
+			text = st.accented(st.heading(text))
+		}
+		b.WriteString(text + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,271 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/playlist"
+)
+
+// listHeight is the number of rows lists show before the first resize
+const listHeight = 20
+
+type inputMode int
+
+const (
+	noInput inputMode = iota
+	createInput
+	renameInput
+)
+
+// playlists is the playlist management view. All changes are sent to
+// the broker, which answers with a PLAYLIST_UPDATE event.
+type playlists struct {
+	broker  Broker
+	results chan event.Event
+
+	playlists []playlist.Summary
+	cursor    int
+
+	open       *playlist.Playlist
+	itemCursor int
+
+	input     textinput.Model
+	inputMode inputMode
+
+	status string
+	// height is the number of rows of the list
+	height int
+	st     *styles
+}
+
+func newPlaylists(b Broker, results chan event.Event, st *styles) playlists {
+	ti := textinput.New()
+	ti.CharLimit = 100
+	return playlists{broker: b, results: results, input: ti, height: listHeight, st: st}
+}
+
+func (p playlists) send(e event.Event) {
+	sendCommand(p.broker.Sink(), p.results, e)
+}
+
+func (p playlists) load() {
+	p.send(event.New(event.PLAYLISTS, nil))
+}
+
+func (p playlists) capturesInput() bool {
+	return p.inputMode != noInput
+}
+
+func (p playlists) updated(e event.PlaylistUpdate) playlists {
+	d := e.Data()
+	if ps, ok := d["playlists"].([]playlist.Summary); ok {
+		p.playlists = ps
+		p.cursor = min(p.cursor, max(len(ps)-1, 0))
+	}
+	if pl, ok := d["playlist"].(*playlist.Playlist); ok {
+		p.open = pl
+		p.itemCursor = min(p.itemCursor, max(len(pl.Items)-1, 0))
+	}
+	p.status = ""
+	if msg, ok := d["message"].(string); ok {
+		p.status = msg
+	}
+	if err, ok := d["error"].(error); ok {
+		p.status = strings.TrimSpace(p.status + " error: " + err.Error())
+	}
+	return p
+}
+
+// handles reports whether key is one of the view's own keys
+func (p playlists) handles(key string) bool {
+	if p.open != nil {
+		return slices.Contains([]string{"esc", "backspace", "up", "k", "down", "j", "d", "K", "J", "D", "I"}, key)
+	}
+	return slices.Contains([]string{"up", "k", "down", "j", "R", "n", "enter", " ", "r", "a"}, key)
+}
+
+func (p playlists) Update(msg tea.KeyMsg) (playlists, tea.Cmd) {
+	if p.inputMode != noInput {
+		return p.updateInput(msg)
+	}
+	if p.open != nil {
+		return p.updateItems(msg), nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "j":
+		if p.cursor < len(p.playlists)-1 {
+			p.cursor++
+		}
+	case "R":
+		p.load()
+	case "n":
+		p.inputMode = createInput
+		p.input.Placeholder = "new playlist name"
+		p.input.SetValue("")
+		return p, p.input.Focus()
+	}
+
+	sel, ok := p.selected()
+	if !ok {
+		return p, nil
+	}
+	switch msg.String() {
+	case "enter", " ":
+		p.itemCursor = 0
+		p.status = "loading " + sel.Name
+		p.send(event.New(event.PLAYLIST_OPEN, map[any]any{"id": sel.ID}))
+	case "r":
+		p.inputMode = renameInput
+		p.input.Placeholder = "new name"
+		p.input.SetValue(sel.Name)
+		return p, p.input.Focus()
+	case "a":
+		p.send(event.New(event.PLAYLIST_ADD_CURRENT, map[any]any{"id": sel.ID}))
+	}
+	return p, nil
+}
+
+func (p playlists) updateItems(msg tea.KeyMsg) playlists {
+	items := p.open.Items
+	switch msg.String() {
+	case "esc", "backspace":
+		p.open = nil
+	case "up", "k":
+		if p.itemCursor > 0 {
+			p.itemCursor--
+		}
+	case "down", "j":
+		if p.itemCursor < len(items)-1 {
+			p.itemCursor++
+		}
+	case "d":
+		if len(items) > 0 {
+			p.send(event.New(event.PLAYLIST_REMOVE, map[any]any{
+				"playlist": p.open, "positions": []int{p.itemCursor},
+			}))
+		}
+	case "K":
+		if p.itemCursor > 0 {
+			p.send(event.New(event.PLAYLIST_MOVE, map[any]any{
+				"playlist": p.open, "from": p.itemCursor, "to": p.itemCursor - 1,
+			}))
+			p.itemCursor--
+		}
+	case "J":
+		if p.itemCursor < len(items)-1 {
+			p.send(event.New(event.PLAYLIST_MOVE, map[any]any{
+				"playlist": p.open, "from": p.itemCursor, "to": p.itemCursor + 1,
+			}))
+			p.itemCursor++
+		}
+	case "D":
+		p.send(event.New(event.PLAYLIST_DEDUPE, map[any]any{
+			"playlist": p.open, "by": playlist.BY_ID,
+		}))
+	case "I":
+		p.send(event.New(event.PLAYLIST_DEDUPE, map[any]any{
+			"playlist": p.open, "by": playlist.BY_ISRC,
+		}))
+	}
+	return p
+}
+
+func (p playlists) updateInput(msg tea.KeyMsg) (playlists, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		p.inputMode = noInput
+		p.input.Blur()
+		return p, nil
+	case "enter":
+		name := strings.TrimSpace(p.input.Value())
+		mode := p.inputMode
+		p.inputMode = noInput
+		p.input.Blur()
+		if name == "" {
+			return p, nil
+		}
+		if mode == createInput {
+			p.send(event.New(event.PLAYLIST_CREATE, map[any]any{"name": name}))
+		} else if sel, ok := p.selected(); ok {
+			p.send(event.New(event.PLAYLIST_RENAME, map[any]any{"id": sel.ID, "name": name}))
+		}
+		return p, nil
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return p, cmd
+}
+
+func (p playlists) selected() (playlist.Summary, bool) {
+	if p.cursor >= len(p.playlists) {
+		return playlist.Summary{}, false
+	}
+	return p.playlists[p.cursor], true
+}
+
+func (p playlists) View() string {
+	var b strings.Builder
+	if p.open != nil {
+		fmt.Fprintf(&b, "%s  (%d items)\n", p.st.heading(p.open.Name), len(p.open.Items))
+		b.WriteString(p.st.muted("esc back, d remove, K/J move up/down, D dedupe by id, I dedupe by ISRC") + "\n\n")
+		start, end := window(len(p.open.Items), p.itemCursor, p.height)
+		for i, it := range p.open.Items[start:end] {
+			row := fmt.Sprintf("%3d. %s - %s", it.Position+1, it.Artists, it.Name)
+			b.WriteString(p.st.row(start+i == p.itemCursor, row) + "\n")
+		}
+	} else {
+		b.WriteString(p.st.heading("Playlists") + "\n")
+		b.WriteString(p.st.muted("enter open, n new, r rename, a add current track, R reload") + "\n\n")
+		start, end := window(len(p.playlists), p.cursor, p.height)
+		for i, pl := range p.playlists[start:end] {
+			row := fmt.Sprintf("%s  (%d)", pl.Name, pl.Tracks)
+			b.WriteString(p.st.row(start+i == p.cursor, row) + "\n")
+		}
+	}
+	if p.inputMode != noInput {
+		fmt.Fprintf(&b, "\n%s\n", p.input.View())
+	}
+	if p.status != "" {
+		fmt.Fprintf(&b, "\n%s\n", p.status)
+	}
+	return b.String()
+}
+
+// click moves the cursor to the row at line of the view. It reports
+// whether the row was under the cursor already.
+func (p playlists) click(line int) (playlists, bool) {
+	// below the title and the hint
+	line -= 3
+	cursor, n := &p.cursor, len(p.playlists)
+	if p.open != nil {
+		cursor, n = &p.itemCursor, len(p.open.Items)
+	}
+	start, end := window(n, *cursor, p.height)
+	if line < 0 || start+line >= end {
+		return p, false
+	}
+	again := *cursor == start+line
+	*cursor = start + line
+	return p, again
+}
+
+// window returns the bounds of the slice of n rows of the given height
+// that keeps the cursor visible
+func window(n, cursor, height int) (int, int) {
+	if n <= height {
+		return 0, n
+	}
+	start := max(cursor-height/2, 0)
+	start = min(start, n-height)
+	return start, start + height
+}
@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/podcast"
+)
+
+// podcasts is the view of the saved shows and their episodes. The
+// broker answers requests with a PODCAST_UPDATE event.
+type podcasts struct {
+	broker  Broker
+	results chan event.Event
+
+	shows  []podcast.Show
+	cursor int
+
+	open      *podcast.Show
+	episodes  []podcast.Episode
+	epCursor  int
+	unplayed  bool // only the episodes the user hasn't started
+	requested bool // the shows were requested once
+
+	status string
+	// height is the number of rows of the list
+	height int
+	st     *styles
+}
+
+func newPodcasts(b Broker, results chan event.Event, st *styles) podcasts {
+	return podcasts{broker: b, results: results, height: listHeight, st: st}
+}
+
+func (p podcasts) send(e event.Event) {
+	sendCommand(p.broker.Sink(), p.results, e)
+}
+
+// load requests the shows the first time the view is opened
+func (p podcasts) load() podcasts {
+	if !p.requested {
+		p.requested = true
+		p.status = "loading shows"
+		p.send(event.New(event.PODCASTS, nil))
+	}
+	return p
+}
+
+func (p podcasts) updated(e event.PodcastUpdate) podcasts {
+	d := e.Data()
+	if shows, ok := d["shows"].([]podcast.Show); ok {
+		p.shows = shows
+		p.cursor = min(p.cursor, max(len(shows)-1, 0))
+	}
+	if show, ok := d["show"].(podcast.Show); ok {
+		p.open = &show
+		p.episodes, _ = d["episodes"].([]podcast.Episode)
+		p.epCursor = 0
+	}
+	p.status = ""
+	if err, ok := d["error"].(error); ok {
+		p.status = "error: " + err.Error()
+	}
+	return p
+}
+
+// visible returns the episodes shown with the current filter
+func (p podcasts) visible() []podcast.Episode {
+	if !p.unplayed {
+		return p.episodes
+	}
+	var eps []podcast.Episode
+	for _, e := range p.episodes {
+		if e.Unplayed() {
+			eps = append(eps, e)
+		}
+	}
+	return eps
+}
+
+// handles reports whether key is one of the view's own keys
+func (p podcasts) handles(key string) bool {
+	if p.open != nil {
+		return slices.Contains([]string{"esc", "backspace", "up", "k", "down", "j", "enter", " ", "u", "R"}, key)
+	}
+	return slices.Contains([]string{"up", "k", "down", "j", "enter", " ", "R"}, key)
+}
+
+func (p podcasts) Update(msg tea.KeyMsg) (podcasts, tea.Cmd) {
+	if p.open != nil {
+		return p.updateEpisodes(msg), nil
+	}
+	switch msg.String() {
+	case "up", "k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "j":
+		if p.cursor < len(p.shows)-1 {
+			p.cursor++
+		}
+	case "R":
+		p.status = "loading shows"
+		p.send(event.New(event.PODCASTS, nil))
+	case "enter", " ":
+		if p.cursor < len(p.shows) {
+			show := p.shows[p.cursor]
+			p.status = "loading " + show.Name
+			p.send(event.New(event.PODCAST_OPEN, map[any]any{"show": show}))
+		}
+	}
+	return p, nil
+}
+
+// click moves the cursor to the row at line of the view. It reports
+// whether the row was under the cursor already.
+func (p podcasts) click(line int) (podcasts, bool) {
+	// below the title and the hint
+	line -= 3
+	cursor, n := &p.cursor, len(p.shows)
+	if p.open != nil {
+		cursor, n = &p.epCursor, len(p.visible())
+	}
+	start, end := window(n, *cursor, p.height)
+	if line < 0 || start+line >= end {
+		return p, false
+	}
+	again := *cursor == start+line
+	*cursor = start + line
+	return p, again
+}
+
+func (p podcasts) updateEpisodes(msg tea.KeyMsg) podcasts {
+	eps := p.visible()
+	switch msg.String() {
+	case "esc", "backspace":
+		p.open, p.episodes = nil, nil
+	case "up", "k":
+		if p.epCursor > 0 {
+			p.epCursor--
+		}
+	case "down", "j":
+		if p.epCursor < len(eps)-1 {
+			p.epCursor++
+		}
+	case "u":
+		p.unplayed = !p.unplayed
+		p.epCursor = 0
+	case "R":
+		p.status = "loading " + p.open.Name
+		p.send(event.New(event.PODCAST_OPEN, map[any]any{"show": *p.open}))
+	case "enter", " ":
+		if p.epCursor < len(eps) {
+			p.send(event.New(event.EPISODE_PLAY, map[any]any{"show": *p.open, "episode": eps[p.epCursor]}))
+		}
+	}
+	return p
+}
+
+func (p podcasts) View() string {
+	var b strings.Builder
+	if p.open != nil {
+		eps := p.visible()
+		filter := "all episodes"
+		if p.unplayed {
+			filter = "unplayed episodes"
+		}
+		fmt.Fprintf(&b, "%s  (%s)\n", p.st.heading(p.open.Name), filter)
+		b.WriteString(p.st.muted("esc back, enter play from the resume point, u toggle unplayed, R reload") + "\n\n")
+		start, end := window(len(eps), p.epCursor, p.height)
+		for i, e := range eps[start:end] {
+			row := fmt.Sprintf("%s  %-12s %s", e.Released, e.Progress(), e.Name)
+			b.WriteString(p.st.row(start+i == p.epCursor, row) + "\n")
+		}
+	} else {
+		b.WriteString(p.st.heading("Podcasts") + "\n")
+		b.WriteString(p.st.muted("enter open, R reload") + "\n\n")
+		start, end := window(len(p.shows), p.cursor, p.height)
+		for i, s := range p.shows[start:end] {
+			row := fmt.Sprintf("%s  (%s)", s.Name, s.Publisher)
+			b.WriteString(p.st.row(start+i == p.cursor, row) + "\n")
+		}
+	}
+	if p.status != "" {
+		fmt.Fprintf(&b, "\n%s\n", p.status)
+	}
+	return b.String()
+}
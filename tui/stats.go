@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moritz-tiesler/spoli/history"
+)
+
+const topN = 10
+
+type statsMsg struct {
+	rng   history.Range
+	plays []history.Play
+	err   error
+}
+
+type exportMsg struct {
+	paths []string
+	err   error
+}
+
+// stats is the listening history view
+type stats struct {
+	history History
+	rng     history.Range
+
+	plays  []history.Play
+	stats  history.Stats
+	err    error
+	status string
+	st     *styles
+}
+
+func (s stats) load() tea.Cmd {
+	h, rng := s.history, s.rng
+	return func() tea.Msg {
+		if h == nil {
+			return statsMsg{rng: rng, err: fmt.Errorf("no listening history available")}
+		}
+		now := time.Now()
+		plays, err := h.Range(rng.Since(now), now)
+		return statsMsg{rng, plays, err}
+	}
+}
+
+func (s stats) loaded(msg statsMsg) stats {
+	if msg.rng != s.rng {
+		// range changed while loading
+		return s
+	}
+	s.plays, s.err = msg.plays, msg.err
+	s.stats = history.Compute(msg.plays, topN)
+	return s
+}
+
+// handles reports whether key is one of the view's own keys
+func (s stats) handles(key string) bool {
+	return slices.Contains([]string{"left", "h", "right", "l", "e"}, key)
+}
+
+func (s stats) Update(msg tea.Msg) (stats, tea.Cmd) {
+	switch msg := msg.(type) {
+	case exportMsg:
+		if msg.err != nil {
+			s.status = fmt.Sprintf("export failed: %s", msg.err)
+		} else {
+			s.status = "exported to " + strings.Join(msg.paths, ", ")
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "left", "h":
+			if s.rng > history.TODAY {
+				s.rng--
+				return s, s.load()
+			}
+		case "right", "l":
+			if s.rng < history.ALL {
+				s.rng++
+				return s, s.load()
+			}
+		case "e":
+			return s, s.export()
+		}
+	}
+	return s, nil
+}
+
+// export writes the plays of the current range as CSV and JSON
+// into the working directory
+func (s stats) export() tea.Cmd {
+	plays, rng := s.plays, s.rng
+	return func() tea.Msg {
+		base := fmt.Sprintf("spoli-history-%s-%s", rng, time.Now().Format("20060102-150405"))
+		var paths []string
+		for ext, write := range map[string]func(*os.File, []history.Play) error{
+			".csv":  func(f *os.File, p []history.Play) error { return history.WriteCSV(f, p) },
+			".json": func(f *os.File, p []history.Play) error { return history.WriteJSON(f, p) },
+		} {
+			path, err := filepath.Abs(base + ext)
+			if err != nil {
+				return exportMsg{err: err}
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return exportMsg{err: err}
+			}
+			err = write(f, plays)
+			f.Close()
+			if err != nil {
+				return exportMsg{err: err}
+			}
+			paths = append(paths, path)
+		}
+		return exportMsg{paths: paths}
+	}
+}
+
+func (s stats) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Listening stats: < %s >  (h/l change range, e export)\n\n", s.rng)
+	if s.err != nil {
+		b.WriteString(s.st.failed("error: "+s.err.Error()) + "\n")
+		return b.String()
+	}
+
+	st := s.stats
+	fmt.Fprintf(&b, "%d plays, %s listened, %d skipped\n\n", st.Plays, formatDuration(st.Listened), st.Skips)
+
+	b.WriteString(s.st.heading("Top tracks") + "\n")
+	for i, c := range st.TopTracks {
+		fmt.Fprintf(&b, "%2d. %s  (%d plays, %s)\n", i+1, c.Name, c.Plays, formatDuration(c.Listened))
+	}
+	b.WriteString("\n" + s.st.heading("Top artists") + "\n")
+	for i, c := range st.TopArtists {
+		fmt.Fprintf(&b, "%2d. %s  (%d plays, %s)\n", i+1, c.Name, c.Plays, formatDuration(c.Listened))
+	}
+
+	b.WriteString("\n" + s.st.heading("Hours") + "\n")
+	var longest time.Duration
+	for _, d := range st.Hours {
+		longest = max(longest, d)
+	}
+	for h, d := range st.Hours {
+		bar := 0
+		if longest > 0 {
+			bar = int(30 * d / longest)
+		}
+		fmt.Fprintf(&b, "%02d %s %s\n", h, s.st.accented(strings.Repeat("▇", bar)), formatDuration(d))
+	}
+
+	if s.status != "" {
+		fmt.Fprintf(&b, "\n%s\n", s.status)
+	}
+	return b.String()
+}
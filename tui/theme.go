@@ -0,0 +1,112 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/moritz-tiesler/spoli/theme"
+)
+
+// styles renders the parts of the TUI in the colors of the palette. The
+// views share it, so the accent of a cover shows everywhere at once.
+type styles struct {
+	palette theme.Palette
+	// accent is the color of the cover, if the palette's is replaced
+	accent *lipgloss.AdaptiveColor
+}
+
+func (s *styles) accentColor() lipgloss.AdaptiveColor {
+	if s.accent != nil {
+		return *s.accent
+	}
+	return s.palette.Accent
+}
+
+func (s *styles) accented(text string) string {
+	return lipgloss.NewStyle().Foreground(s.accentColor()).Render(text)
+}
+
+func (s *styles) muted(text string) string {
+	return lipgloss.NewStyle().Foreground(s.palette.Muted).Render(text)
+}
+
+func (s *styles) failed(text string) string {
+	return lipgloss.NewStyle().Foreground(s.palette.Error).Render(text)
+}
+
+func (s *styles) liked(text string) string {
+	return lipgloss.NewStyle().Foreground(s.palette.Liked).Render(text)
+}
+
+func (s *styles) heading(text string) string {
+	return lipgloss.NewStyle().Bold(true).Render(text)
+}
+
+// row renders a row of a list, the selected one marked in the accent
+// color
+func (s *styles) row(selected bool, text string) string {
+	if selected {
+		return lipgloss.NewStyle().Foreground(s.accentColor()).Bold(true).Render("▸ " + text)
+	}
+	return "  " + text
+}
+
+// border is the border of a pane, in the accent color if it has the
+// focus
+func (s *styles) border(focused bool) lipgloss.Style {
+	c := s.palette.Muted
+	if focused {
+		c = s.accentColor()
+	}
+	return lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(c)
+}
+
+// accentMsg carries the accent color of the cover at url
+type accentMsg struct {
+	url   string
+	color lipgloss.AdaptiveColor
+	err   error
+}
+
+// refreshAccent looks up the accent color of the current cover
+func (m model) refreshAccent() (model, tea.Cmd) {
+	if m.accentOf == nil {
+		return m, nil
+	}
+	url := coverURL(m.track())
+	if url == m.accentURL {
+		return m, nil
+	}
+	m.accentURL = url
+	if url == "" {
+		m.st.accent = nil
+		return m, nil
+	}
+	accentOf := m.accentOf
+	return m, func() tea.Msg {
+		c, err := accentOf(url)
+		return accentMsg{url: url, color: c, err: err}
+	}
+}
+
+// accentLoaded uses the accent color of the current cover, or the
+// palette's if the cover has none
+func (m model) accentLoaded(msg accentMsg) model {
+	if msg.url != m.accentURL {
+		return m
+	}
+	m.st.accent = nil
+	if msg.err == nil {
+		m.st.accent = &msg.color
+	}
+	return m
+}
+
+// setTheme switches to the palette called name
+func (m model) setTheme(name string) (model, error) {
+	p, err := m.themes.Get(name)
+	if err != nil {
+		return m, err
+	}
+	m.st.palette = p
+	return m, nil
+}
@@ -2,23 +2,47 @@ package tui
 
 import (
 	"fmt"
-	"log"
+	"strings"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/history"
+	"github.com/moritz-tiesler/spoli/library"
+	"github.com/moritz-tiesler/spoli/link"
+	"github.com/moritz-tiesler/spoli/theme"
+	"github.com/zmb3/spotify/v2"
 
 	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type Broker interface {
-	Source() chan event.Event
+	Subscribe() (<-chan event.Event, func())
 	Sink() chan event.Event
 	FlushSource()
 	FlushSink()
 }
 
+// History is the local listening history shown in the stats view.
+type History interface {
+	Range(from, to time.Time) ([]history.Play, error)
+}
+
+type view int
+
+const (
+	playerView view = iota
+	statsView
+	playlistsView
+	podcastsView
+	// detailView shows artists and albums, it is opened from the others
+	detailView
+)
+
+var viewNames = []string{"player", "stats", "playlists", "podcasts"}
+
 type model struct {
 	choices  []string         // items on the to-do list
 	cursor   int              // which to-do list item our cursor is pointing at
@@ -26,148 +50,268 @@ type model struct {
 
 	songInfo tea.Model
 
-	broker Broker
-
+	broker  Broker
+	events  chan event.Event
+	results chan event.Event // results of commands the broker didn't take
+
+	view      view
+	stats     stats
+	playlists playlists
+	podcasts  podcasts
+	detail    detail
+	// detailFrom is the view the detail view goes back to
+	detailFrom view
+
+	keymap   Keymap
+	pending  []string // keys of an unfinished chord
+	palette  palette
+	showHelp bool
+	devices  []string
+	status   string
+	// statusSeq tells the timer of an old status from the current one
+	statusSeq int
+
+	// the log pane tails the recent log records
+	logs LogTail
+	// logsOn is set while the log pane is visible and refreshed
+	logsOn   bool
+	logSeq   int
 	viewport viewport.Model
+
+	layout     Layout
+	layoutFile string
+	// width and height are the size of the terminal
+	width, height int
+	// focus is the pane that gets the scroll keys
+	focus  pane
+	zoomed bool
+	// seekRect is the pane the progress bar is dragged in
+	seekRect rect
+	draw     func(url string, w, h int) (string, error)
+	art      art
+	queue    queue
+	lyrics   lyricsView
+
+	st     *styles
+	themes theme.Palettes
+	// accentOf finds the accent color of a cover, nil if the palette's
+	// accent is used
+	accentOf  func(url string) (lipgloss.AdaptiveColor, error)
+	accentURL string
+}
+
+// Options configure the TUI
+type Options struct {
+	History History
+	Keymap  Keymap
+	// StatsRange is the range the stats view starts with
+	StatsRange history.Range
+	// Logs is shown in the log pane, it may be nil
+	Logs LogTail
+	// Art draws the cover at url in w by h cells for the art pane, it may
+	// be nil
+	Art    func(url string, w, h int) (string, error)
+	Layout Layout
+	// LayoutFile is where the layout action saves the layout
+	LayoutFile string
+	// Themes are the palettes the theme action switches between, Theme
+	// is the name of the one to start with
+	Themes theme.Palettes
+	Theme  string
+	// Accent returns the accent color of the cover at url, it may be nil
+	Accent func(url string) (lipgloss.AdaptiveColor, error)
+}
+
+// LogTail holds the recent log records
+type LogTail interface {
+	Lines() []string
 }
 
 // TODO pub sub model: models sub to broker channel events
-func InitialModel(b Broker) model {
+func InitialModel(b Broker, o Options) model {
+	events := make(chan event.Event, 16)
+	results := make(chan event.Event, 16)
 
 	go func() {
-		for e := range b.Source() {
-			log.Printf("Dispatching %s\n", e)
-			log.Printf("%+v\n", subs)
+		source, _ := b.Subscribe()
+		for e := range source {
 			cbs := subs[e.String()]
 			for _, cb := range cbs {
-				log.Printf("Dispatching %s to %T\n", e, cb)
 				cb(e)
 			}
+			events <- e
 		}
+		close(events)
 	}()
+	palette, ok := o.Themes[o.Theme]
+	if !ok {
+		palette = theme.Builtin[theme.Default]
+	}
+	st := &styles{palette: palette}
 	m := model{
-		// Our to-do list is a grocery list
-		choices: []string{
-			event.TOGGLE_PLAY.String(),
-			event.PREV.String(),
-			event.NEXT.String(),
-		},
+		// the commands that can be run from the list
+		choices: []string{"toggle", "prev", "next"},
 
 		// A map which indicates which choices are selected. We're using
 		// the  map like a mathematical set. The keys refer to the indexes
 		// of the `choices` slice, above.
-		selected: make(map[int]struct{}),
-		songInfo: songInfo{},
-		broker:   b,
-		// viewport: viewport.New(30, 5),
-	}
-
-	sub(event.New(event.SONGCHANGE, nil), func(e event.Event) {
-		if newSongEvent, ok := e.(event.SongChange); ok {
-			d := newSongEvent.Data()
-			song := d["songName"]
-			m.songInfo, _ = m.songInfo.Update(song)
-		} else {
-			log.Printf("expected song change event, got %+v", e)
-			m.songInfo, _ = m.songInfo.Update("ERROR")
-		}
-		log.Printf("Calling base model update")
-		m.Update(m.songInfo)
-	})
+		selected:   make(map[int]struct{}),
+		songInfo:   songInfo{st: st},
+		broker:     b,
+		events:     events,
+		results:    results,
+		stats:      stats{history: o.History, rng: o.StatsRange, st: st},
+		playlists:  newPlaylists(b, results, st),
+		podcasts:   newPodcasts(b, results, st),
+		detail:     newDetail(b, results, st),
+		keymap:     o.Keymap,
+		palette:    newPalette(st),
+		logs:       o.Logs,
+		viewport:   viewport.New(80, 10),
+		layout:     o.Layout,
+		layoutFile: o.LayoutFile,
+		focus:      libraryPane,
+		draw:       o.Art,
+		st:         st,
+		themes:     o.Themes,
+		accentOf:   o.Accent,
+	}
 
 	return m
 }
 
+// eventMsg carries a broker event into the update cycle
+type eventMsg struct {
+	event.Event
+}
+
+func waitForEvent(events <-chan event.Event) tea.Cmd {
+	return func() tea.Msg {
+		e, ok := <-events
+		if !ok {
+			return nil
+		}
+		return eventMsg{e}
+	}
+}
+
+// resultMsg carries the result of a command the broker didn't take
+type resultMsg struct {
+	event.Event
+}
+
+func waitForResult(results <-chan event.Event) tea.Cmd {
+	return func() tea.Msg {
+		return resultMsg{<-results}
+	}
+}
+
+// clearStatusMsg clears the status if it wasn't replaced since
+type clearStatusMsg struct {
+	seq int
+}
+
+// statusTimeout is how long the result of a command is shown
+const statusTimeout = 4 * time.Second
+
 func (m model) Init() tea.Cmd {
-	// Just return `nil`, which means "no I/O right now, please."
-	return nil
+	return tea.Batch(waitForEvent(m.events), waitForResult(m.results))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
-	// Is it a key press?
-	case songInfo:
-		log.Printf("base model received songInfo=%v\n", msg)
-		m.songInfo = msg
-		m.View()
-		// TODO: how to pass new songinfo into update cycle?
-	case tea.KeyMsg:
+	case eventMsg:
+		switch e := msg.Event.(type) {
+		case event.SongChange:
+			m.songInfo, _ = m.songInfo.Update(e.Data()["songName"])
+		case event.StateChange:
+			m.songInfo, _ = m.songInfo.Update(e.Data()["state"])
+			m, cmd := m.refreshPanes()
+			m, accentCmd := m.refreshAccent()
+			return m, tea.Batch(cmd, accentCmd, waitForEvent(m.events))
+		case event.QueueList:
+			m.queue = m.queue.updated(e)
+		case event.LyricsUpdate:
+			m.lyrics = m.lyrics.updated(e)
+		case event.LibraryState:
+			m.songInfo, _ = m.songInfo.Update(e)
+		case event.PlaylistUpdate:
+			m.playlists = m.playlists.updated(e)
+		case event.PodcastUpdate:
+			m.podcasts = m.podcasts.updated(e)
+		case event.Detail:
+			m.detail = m.detail.updated(e)
+		case event.DeviceList:
+			m.devices, _ = e.Data()["devices"].([]string)
+		case event.Result:
+			m, cmd := m.showResult(e)
+			return m, tea.Batch(cmd, waitForEvent(m.events))
+		}
+		return m, waitForEvent(m.events)
 
-		// Cool, what was the actual key pressed?
-		switch msg.String() {
+	case resultMsg:
+		m, cmd := m.showResult(msg.Event)
+		return m, tea.Batch(cmd, waitForResult(m.results))
 
-		// These keys should exit the program.
-		case "ctrl+c", "q":
-			return m, tea.Quit
+	case logTickMsg:
+		if !m.logsOn || msg.seq != m.logSeq {
+			return m, nil
+		}
+		m = m.refreshLogs()
+		return m, m.logTick()
 
-		// The "up" and "k" keys move the cursor up
-		case "up", "k":
-			if m.cursor > 0 {
-				m.cursor--
-			}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m.refreshPanes()
+
+	case artMsg:
+		m.art = m.art.loaded(msg)
+
+	case accentMsg:
+		m = m.accentLoaded(msg)
+
+	case clearStatusMsg:
+		if msg.seq == m.statusSeq {
+			m.status = ""
+		}
+
+	case statsMsg:
+		m.stats = m.stats.loaded(msg)
+
+	case exportMsg:
+		m.stats, _ = m.stats.Update(msg)
 
-		// The "down" and "j" keys move the cursor down
-		case "down", "j":
-			if m.cursor < len(m.choices)-1 {
-				m.cursor++
+	// Is it a key press?
+	case tea.KeyMsg:
+		m.status = ""
+
+		if m.palette.active {
+			var line string
+			var cmd tea.Cmd
+			m.palette, line, cmd = m.palette.Update(m, msg)
+			if line == "" {
+				return m, cmd
 			}
+			m, runCmd := m.runCommand(line)
+			return m, tea.Batch(cmd, runCmd)
+		}
 
-			// The "enter" key and the spacebar (a literal space) toggle
-			// the selected state for the item that the cursor is pointing at.
-		case "enter", " ":
-			// _, ok := m.selected[m.cursor]
-			// if ok {
-			// 	delete(m.selected, m.cursor)
-			// } else {
-			// }
-
-			clear(m.selected)
-			m.selected[m.cursor] = struct{}{}
-
-			switch m.cursor {
-			case 0:
-				sendOrTimeout(
-					m.broker.Sink(),
-					event.New(event.TOGGLE_PLAY, nil),
-					func() <-chan time.Time { return time.After(time.Second * 2) },
-				)
-			case 1:
-				// m.broker.FlushSource()
-				sendOrTimeout(
-					m.broker.Sink(),
-					event.New(event.PREV, nil),
-					func() <-chan time.Time { return time.After(time.Second * 2) },
-				)
-				// e := <-m.broker.Source()
-				// if newSongEvent, ok := e.(event.SongChange); ok {
-				// 	d := newSongEvent.Data()
-				// 	song := d["songName"]
-				// 	m.songInfo, _ = m.songInfo.Update(song)
-				// } else {
-				// 	log.Printf("expected song change event, got %+v", e)
-				// 	m.songInfo, _ = m.songInfo.Update("ERROR")
-				// }
-			case 2:
-				// m.broker.FlushSource()
-				sendOrTimeout(
-					m.broker.Sink(),
-					event.New(event.NEXT, nil),
-					func() <-chan time.Time { return time.After(time.Second * 2) },
-				)
-				// songName = event.NEXT.String()
-				// e := <-m.broker.Source()
-				// if newSongEvent, ok := e.(event.SongChange); ok {
-				// 	d := newSongEvent.Data()
-				// 	song := d["songName"]
-				// 	m.songInfo, _ = m.songInfo.Update(song)
-				// } else {
-				// 	log.Printf("expected song change event, got %+v", e)
-				// 	m.songInfo, _ = m.songInfo.Update("ERROR")
-				// }
+		if m.showHelp {
+			switch msg.String() {
+			case "?", "esc", "q":
+				m.showHelp = false
 			}
+			return m, nil
+		}
 
+		if m, cmd, ok := m.scrollPane(msg); ok {
+			return m, cmd
 		}
+		return m.viewKey(msg)
+
+	case tea.MouseMsg:
+		return m.mouse(msg)
 
 	}
 
@@ -176,55 +320,284 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m model) View() string {
-	log.Printf("model.View called with si=%+v\n", m)
-	// The header
-	s := fmt.Sprintf("%s\n\n", m.songInfo.View())
+// viewKey passes msg to the current view, or runs the command bound to it
+func (m model) viewKey(msg tea.KeyMsg) (model, tea.Cmd) {
+	if m.view == playlistsView && m.playlists.capturesInput() {
+		var cmd tea.Cmd
+		m.playlists, cmd = m.playlists.Update(msg)
+		return m, cmd
+	}
 
-	// Iterate over our choices
-	for i, choice := range m.choices {
+	// bracketed paste, e.g. a link copied from the Spotify app
+	if msg.Paste {
+		return m.pasted(string(msg.Runes)), nil
+	}
 
-		// Is the cursor pointing at this choice?
-		cursor := " " // no cursor
-		if m.cursor == i {
-			cursor = ">" // cursor!
+	// the views get the first chance at keys outside of chords
+	if len(m.pending) == 0 {
+		switch {
+		case m.view == statsView && m.stats.handles(msg.String()):
+			var cmd tea.Cmd
+			m.stats, cmd = m.stats.Update(msg)
+			return m, cmd
+		case m.view == playlistsView && m.playlists.handles(msg.String()):
+			var cmd tea.Cmd
+			m.playlists, cmd = m.playlists.Update(msg)
+			return m, cmd
+		case m.view == podcastsView && m.podcasts.handles(msg.String()):
+			var cmd tea.Cmd
+			m.podcasts, cmd = m.podcasts.Update(msg)
+			return m, cmd
+		case m.view == detailView && m.detail.handles(msg.String()):
+			switch msg.String() {
+			case "esc", "backspace", "h":
+				if !m.detail.canBack() {
+					return m.setView(m.detailFrom)
+				}
+			}
+			var cmd tea.Cmd
+			m.detail, cmd = m.detail.Update(msg)
+			return m, cmd
 		}
+	}
+
+	return m.handleKey(msg.String())
+}
+
+// handleKey runs the command bound to key, or to the chord it completes.
+func (m model) handleKey(key string) (model, tea.Cmd) {
+	seq := append(m.pending, key)
+	cmd, prefix := m.keymap.lookup(seq)
+	if prefix {
+		m.pending = seq
+		return m, nil
+	}
+	m.pending = nil
+	if cmd == "" && len(seq) > 1 {
+		// an unfinished chord followed by an unrelated key
+		return m.handleKey(key)
+	}
+	if cmd == "" {
+		return m, nil
+	}
+	return m.runCommand(cmd)
+}
+
+// runCommand runs a command line like "volume +5".
+func (m model) runCommand(line string) (model, tea.Cmd) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return m, nil
+	}
+	a, ok := findAction(fields[0])
+	if !ok {
+		m.status = fmt.Sprintf("unknown command %q", fields[0])
+		return m, nil
+	}
+	m, cmd, err := a.run(m, fields[1:])
+	if err != nil {
+		m.status = m.st.failed(err.Error())
+	}
+	return m, cmd
+}
+
+func (m model) setView(v view) (model, tea.Cmd) {
+	m.view = v
+	switch v {
+	case statsView:
+		return m, m.stats.load()
+	case playlistsView:
+		m.playlists.load()
+	case podcastsView:
+		m.podcasts = m.podcasts.load()
+	}
+	return m, nil
+}
+
+// track is the item playing now, nil if there is none
+func (m model) track() *spotify.FullTrack {
+	si, _ := m.songInfo.(songInfo)
+	if si.state == nil {
+		return nil
+	}
+	return si.state.Item
+}
+
+// currentTrack is the track playing now
+func (m model) currentTrack() (*spotify.FullTrack, error) {
+	si, _ := m.songInfo.(songInfo)
+	if si.state == nil || si.state.Item == nil || si.state.Item.Type == "episode" {
+		return nil, fmt.Errorf("no track is playing")
+	}
+	return si.state.Item, nil
+}
+
+// openDetail opens the page of an artist or album in the detail view
+func (m model) openDetail(uri spotify.URI) model {
+	if m.view != detailView {
+		m.detailFrom = m.view
+	}
+	m.detail = m.detail.open(uri)
+	m.view = detailView
+	return m
+}
+
+// pasted plays a pasted Spotify URI or link
+func (m model) pasted(s string) model {
+	l, err := link.Parse(s)
+	if err != nil {
+		m.status = m.st.failed(err.Error())
+		return m
+	}
+	m.send(event.New(event.LINK, map[any]any{"link": l}))
+	return m
+}
 
-		// Is this choice selected?
-		checked := " " // not selected
+// logTickMsg refreshes the open log pane
+type logTickMsg struct {
+	seq int
+}
+
+func (m model) logTick() tea.Cmd {
+	seq := m.logSeq
+	return tea.Tick(500*time.Millisecond, func(time.Time) tea.Msg {
+		return logTickMsg{seq}
+	})
+}
+
+// refreshLogs shows the recent records, it follows new records while the
+// log pane is scrolled to the bottom
+func (m model) refreshLogs() model {
+	if m.logs == nil {
+		return m
+	}
+	follow := m.viewport.AtBottom()
+	m.viewport.SetContent(strings.Join(m.logs.Lines(), "\n"))
+	if follow {
+		m.viewport.GotoBottom()
+	}
+	return m
+}
+
+func (m model) send(e event.Event) {
+	sendCommand(m.broker.Sink(), m.results, e)
+}
+
+// showResult shows the outcome of a command sent by the TUI for a while
+func (m model) showResult(e event.Event) (model, tea.Cmd) {
+	id, _ := e.Data()["id"].(string)
+	if !strings.HasPrefix(id, "tui-") {
+		return m, nil
+	}
+	command, _ := e.Data()["command"].(string)
+	if msg, _ := e.Data()["error"].(string); msg != "" {
+		m.status = m.st.failed(fmt.Sprintf("%s failed: %s", command, msg))
+	} else {
+		m.status = command + ": ok"
+	}
+	m.statusSeq++
+	seq := m.statusSeq
+	return m, tea.Tick(statusTimeout, func(time.Time) tea.Msg {
+		return clearStatusMsg{seq}
+	})
+}
+
+func (m model) View() string {
+	if m.showHelp {
+		return "Key bindings\n\n" + m.keymap.Help() + "\nPress ? or esc to close.\n"
+	}
+	w, h := m.bodySize()
+	return m.render(m.geometry(w, h), h) + "\n" + m.footer()
+}
+
+// footer is shown below the panes
+func (m model) footer() string {
+	var s string
+	if m.status != "" {
+		s = m.status + "\n"
+	}
+	switch {
+	case m.palette.active:
+		s += m.palette.View()
+	case len(m.pending) > 0:
+		s += strings.Join(m.pending, " ") + " ..."
+	default:
+		s += m.st.muted("Press ? for help, : for commands, q to quit.")
+	}
+	return strings.TrimRight(s, "\n")
+}
+
+// libraryView is the content of the library pane, width cells wide
+func (m model) libraryView(width int) string {
+	switch m.view {
+	case statsView:
+		return m.stats.View()
+	case playlistsView:
+		return m.playlists.View()
+	case podcastsView:
+		return m.podcasts.View()
+	case detailView:
+		return m.detail.View()
+	}
+	return m.playerView(width)
+}
+
+func (m model) playerView(width int) string {
+	var s string
+	// the now playing pane shows the song otherwise
+	if !m.visible(nowPane) {
+		si, _ := m.songInfo.(songInfo)
+		s = fmt.Sprintf("%s\n\n", si.render(width))
+	}
+
+	// Iterate over our choices
+	for i, choice := range m.choices {
+		s += m.st.row(m.cursor == i, choice)
+		// the choice that ran last
 		if _, ok := m.selected[i]; ok {
-			checked = "x" // selected!
+			s += m.st.muted(" ✓")
 		}
-
-		// Render the row
-		s += fmt.Sprintf("%s [%s] %s\n", cursor, checked, choice)
+		s += "\n"
 	}
+	return s
+}
 
-	// The footer
-	s += "\nPress q to quit.\n"
+// commandTimeout is how long the broker gets to take a command
+const commandTimeout = 2 * time.Second
 
-	gap := "\n"
-	// Send the UI for rendering
-	r := fmt.Sprintf(
-		"%s%s%s",
-		s,
-		gap,
-		m.songInfo.View(),
-	)
-	log.Println("base model render result: ", r)
-	return r
+// sendCommand sends e to the broker, which answers with a RESULT event.
+// If the broker doesn't take it in time, the failure is put on results.
+func sendCommand(sink chan<- event.Event, results chan<- event.Event, e event.Event) {
+	c := event.NewCommand("tui", e)
+	if sendOrTimeout(sink, c, func() <-chan time.Time { return time.After(commandTimeout) }) {
+		return
+	}
+	select {
+	case results <- event.NewResult(c.ID, e.String(), "spoli is busy, try again"):
+	default:
+	}
 }
 
-func sendOrTimeout(ch chan<- event.Event, v event.Event, or func() <-chan time.Time) {
+func sendOrTimeout(ch chan<- event.Event, v event.Event, or func() <-chan time.Time) bool {
 	select {
 	case ch <- v:
+		return true
 	case <-or():
+		return false
 	}
 }
 
 type songInfo struct {
+	st     *styles
 	text   string
+	state  *spotify.PlayerState
 	broker Broker
+	// seekTo is the position the progress bar is dragged to
+	seekTo *time.Duration
+
+	// the library state of the current item and its album or show
+	item  *library.Item
+	saved map[library.Kind]bool
 }
 
 func (si songInfo) Init() tea.Cmd {
@@ -235,15 +608,170 @@ func (si songInfo) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case string:
 		si.text = msg
+	case *spotify.PlayerState:
+		si.state = msg
+	case event.LibraryState:
+		si.item, _ = msg.Data()["item"].(*library.Item)
+		si.saved, _ = msg.Data()["saved"].(map[library.Kind]bool)
 	default:
 
 	}
 	return si, nil
 }
 
+// toggleSaved returns the event that flips the saved state of the
+// current item, or of its album or show if parent is set
+func (si songInfo) toggleSaved(parent bool) (event.Event, bool) {
+	if si.item == nil {
+		return nil, false
+	}
+	kind := si.item.Kind
+	if parent {
+		kind = si.item.Parent
+	}
+	if kind == "" {
+		return nil, false
+	}
+	e := event.LIBRARY_SAVE
+	if si.saved[kind] {
+		e = event.LIBRARY_REMOVE
+	}
+	return event.New(e, map[any]any{"kind": kind}), true
+}
+
+func (si songInfo) savedView() string {
+	if si.item == nil {
+		return ""
+	}
+	var parts []string
+	for _, k := range []library.Kind{si.item.Kind, si.item.Parent} {
+		if k == "" {
+			continue
+		}
+		mark := "♡"
+		if si.saved[k] {
+			mark = si.st.liked("♥")
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", mark, k))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// the lines of the song info
+const (
+	songTitleLine = iota
+	// progressLine starts with the progress bar
+	progressLine
+	// volumeLine starts with volumeLabel and the volume bar
+	volumeLine
+	songLines
+)
+
+const (
+	volumeLabel    = "vol "
+	volumeBarWidth = 10
+	// minBarWidth is the progress bar's width in narrow panes
+	minBarWidth = 10
+)
+
 func (si songInfo) View() string {
-	log.Printf("songInfo.View called with si.text=%s\n", si.text)
-	return si.text
+	return si.render(0)
+}
+
+// render shows the song in width cells, the progress bar fills the room
+// the times leave
+func (si songInfo) render(width int) string {
+	ps := si.state
+	if ps == nil || ps.Item == nil {
+		if saved := si.savedView(); saved != "" {
+			return si.text + "\n" + saved
+		}
+		return si.text
+	}
+	progress, duration := si.progress(), ps.Item.TimeDuration()
+	if si.seekTo != nil {
+		progress = *si.seekTo
+	}
+	bar := si.bar(si.barWidth(width), progress, duration)
+	volume := int(ps.Device.Volume)
+	filled := volume * volumeBarWidth / 100
+	return fmt.Sprintf(
+		"%s\n%s %s\n%s%s%s %3d%%  (%s)  %s",
+		si.text,
+		bar,
+		si.times(progress, duration),
+		volumeLabel,
+		si.st.accented(strings.Repeat("▮", filled)),
+		si.st.muted(strings.Repeat("▯", volumeBarWidth-filled)),
+		volume,
+		ps.Device.Name,
+		si.savedView(),
+	)
+}
+
+// times follows the progress bar, e.g. "▶ 1:23 / 3:45"
+func (si songInfo) times(progress, duration time.Duration) string {
+	icon := "⏸"
+	if si.state.Playing {
+		icon = si.st.accented("▶")
+	}
+	return fmt.Sprintf("%s %s / %s", icon, formatDuration(progress), formatDuration(duration))
+}
+
+// barWidth is the width of the progress bar in a line of width cells,
+// 0 for no width in particular
+func (si songInfo) barWidth(width int) int {
+	if width == 0 {
+		return 2 * minBarWidth
+	}
+	ps := si.state
+	return max(width-lipgloss.Width(si.times(ps.Item.TimeDuration(), ps.Item.TimeDuration()))-1, minBarWidth)
+}
+
+func (si songInfo) bar(width int, progress, duration time.Duration) string {
+	done := 0
+	if duration > 0 {
+		done = min(int(int64(width)*int64(progress)/int64(duration)), width-1)
+	}
+	return si.st.accented(strings.Repeat("━", done)+"●") + si.st.muted(strings.Repeat("─", width-done-1))
+}
+
+// seekAt returns the position at column x of the progress bar in a line
+// of width cells
+func (si songInfo) seekAt(x, width int) (time.Duration, bool) {
+	if si.state == nil || si.state.Item == nil {
+		return 0, false
+	}
+	n := si.barWidth(width)
+	if x < 0 || x >= n {
+		return 0, false
+	}
+	return si.state.Item.TimeDuration() * time.Duration(x) / time.Duration(n-1), true
+}
+
+// volumeAt returns the volume at column x of the volume bar
+func (si songInfo) volumeAt(x int) (int, bool) {
+	x -= len(volumeLabel)
+	if si.state == nil || si.state.Item == nil || x < 0 || x >= volumeBarWidth {
+		return 0, false
+	}
+	return (x + 1) * 100 / volumeBarWidth, true
+}
+
+// progress is the position in the current item as of the last state
+func (si songInfo) progress() time.Duration {
+	if si.state == nil {
+		return 0
+	}
+	return time.Duration(si.state.Progress) * time.Millisecond
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
 }
 
 var subs map[string][]func(event.Event) = map[string][]func(event.Event){}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/moritz-tiesler/spoli/event"
+	"github.com/moritz-tiesler/spoli/logging"
+	"github.com/zmb3/spotify/v2"
+)
+
+var logWatcher = logging.For("watcher")
+
+// StateWatcher polls the player state and publishes every change
+// as a STATECHANGE event on the broker's source.
+type StateWatcher struct {
+	interval time.Duration
+
+	mu        sync.Mutex
+	observers []func(*spotify.PlayerState)
+}
+
+func NewStateWatcher(interval time.Duration) *StateWatcher {
+	return &StateWatcher{interval: interval}
+}
+
+// Observe registers f to be called with every polled state.
+// The state is nil while no device is playing.
+func (w *StateWatcher) Observe(f func(*spotify.PlayerState)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.observers = append(w.observers, f)
+}
+
+func (w *StateWatcher) Run(ctx context.Context, c *Client, b *Broker) {
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	var last *spotify.PlayerState
+	// the first state is published even if nothing plays, new
+	// subscribers like `spoli status` wait for it
+	first := true
+	for {
+		ps, err := c.playerState(ctx)
+		if err != nil {
+			logWatcher.Warn("error polling player state", "err", err)
+		} else {
+			if ps.Device.ID == "" {
+				ps = nil
+			}
+			w.notify(ps)
+			if first || stateDiffers(last, ps) {
+				b.publish(event.New(
+					event.STATECHANGE,
+					map[any]any{"state": ps, "type": playingType(ps)},
+				))
+			}
+			if songOf(last) != songOf(ps) {
+				b.publish(songChanged(ps))
+			}
+			last, first = ps, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (w *StateWatcher) notify(ps *spotify.PlayerState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range w.observers {
+		f(ps)
+	}
+}
+
+func stateDiffers(old, new *spotify.PlayerState) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return songOf(old) != songOf(new) ||
+		old.Playing != new.Playing ||
+		old.Progress != new.Progress ||
+		old.Device != new.Device ||
+		old.ShuffleState != new.ShuffleState ||
+		old.RepeatState != new.RepeatState
+}
+
+func songOf(ps *spotify.PlayerState) string {
+	if ps == nil || ps.Item == nil {
+		return ""
+	}
+	return ps.Item.Name
+}